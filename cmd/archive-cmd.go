@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	dmodule "github.com/ovrclk/akash/x/deployment"
+	dtypes "github.com/ovrclk/akash/x/deployment/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagOlderThan = "older-than"
+	flagDryRun    = "dry-run"
+	flagSync      = "sync"
+)
+
+func init() {
+	rootCmd.AddCommand(archiveCmd())
+}
+
+// archiveCmd represents the archive command
+func archiveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "archive",
+		Short: "manage the local archive of deployments, see `deploy history` to inspect it",
+		Annotations: map[string]string{
+			annotationLenient: "true",
+		},
+	}
+	cmd.AddCommand(archivePruneCmd())
+	return cmd
+}
+
+func archivePruneCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "remove archived deployments closed longer ago than --older-than",
+		Long: `remove archived deployments closed longer ago than --older-than
+
+deployments are recorded as closed when deploy sees them close. with --sync, the
+default, deployments the archive has as active are first looked up on chain and
+recorded as closed now if they were closed while deploy wasn't running`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			olderThan, err := cmd.Flags().GetDuration(flagOlderThan)
+			if err != nil {
+				return err
+			}
+			dryRun, err := cmd.Flags().GetBool(flagDryRun)
+			if err != nil {
+				return err
+			}
+			sync, err := cmd.Flags().GetBool(flagSync)
+			if err != nil {
+				return err
+			}
+
+			list, err := config.ArchivedDeployments("")
+			if err != nil {
+				return err
+			}
+			if sync && config.ChainID != "" {
+				syncArchivedDeployments(list)
+			}
+
+			pruned := 0
+			for _, a := range list {
+				if a.Closed == nil || time.Since(*a.Closed) < olderThan {
+					continue
+				}
+				log := logger.With("owner", a.Owner, "dseq", a.DSeq, "closed", formatTime(a.Closed))
+				if dryRun {
+					log.Info("would remove archived deployment", "dir", a.Dir())
+					continue
+				}
+				if err = a.Remove(); err != nil {
+					return err
+				}
+				pruned++
+				log.Info("removed archived deployment", "dir", a.Dir())
+			}
+			if !dryRun {
+				fmt.Printf("removed %d of %d archived deployments\n", pruned, len(list))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().Duration(flagOlderThan, 30*24*time.Hour, "only remove deployments closed at least this long ago")
+	cmd.Flags().Bool(flagDryRun, false, "only print what would be removed")
+	cmd.Flags().Bool(flagSync, true, "record deployments closed on chain as closed before pruning")
+	return cmd
+}
+
+// syncArchivedDeployments records the active archived deployments closed on chain as closed
+func syncArchivedDeployments(list []*ArchivedDeployment) {
+	dclient := dmodule.AppModuleBasic{}.GetQueryClient(config.CLICtx(config.NewTMClient()))
+	for _, a := range list {
+		if a.Closed != nil {
+			continue
+		}
+		log := logger.With("owner", a.Owner, "dseq", a.DSeq)
+		owner, err := sdk.AccAddressFromBech32(a.Owner)
+		if err != nil {
+			log.Error("invalid owner in archive", "err", err)
+			continue
+		}
+		d, err := dclient.Deployment(dtypes.DeploymentID{Owner: owner, DSeq: a.DSeq})
+		if err != nil {
+			log.Info("couldn't look up deployment on chain", "err", err)
+			continue
+		}
+		if d.State == dtypes.DeploymentClosed {
+			log.Info("deployment was closed on chain")
+			if err = a.Close(); err != nil {
+				log.Error("error updating archive", "err", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,502 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ovrclk/akash/provider/cluster"
+	"github.com/ovrclk/akash/sdl"
+	mtypes "github.com/ovrclk/akash/x/market/types"
+)
+
+const (
+	// archiveMetadataFile is the file in a deployment's archive directory holding its ArchivedDeployment
+	archiveMetadataFile = "metadata.json"
+
+	archiveStateActive = "active"
+	archiveStateClosed = "closed"
+)
+
+// ArchivedDeployment is the local history of a deployment, stored in the
+// `$HOME/.akash-deploy/deployments/<owner>.<dseq>/` directory with a file for each sdl revision
+type ArchivedDeployment struct {
+	Owner     string             `json:"owner"`
+	DSeq      uint64             `json:"dseq"`
+	Created   time.Time          `json:"created"`
+	Updated   time.Time          `json:"updated"`
+	Closed    *time.Time         `json:"closed,omitempty"`
+	Revisions []ArchivedRevision `json:"revisions"`
+	Txs       []ArchivedTx       `json:"txs"`
+	Leases    []ArchivedLease    `json:"leases"`
+	Bids      []ArchivedBid      `json:"bids,omitempty"`
+
+	dir string
+	mu  sync.Mutex
+}
+
+// ArchivedRevision is an sdl the deployment was created or updated with
+type ArchivedRevision struct {
+	Revision int       `json:"revision"`
+	Version  string    `json:"version"`
+	Created  time.Time `json:"created"`
+	Note     string    `json:"note,omitempty"`
+}
+
+// ArchivedTx is a transaction sent for the deployment
+type ArchivedTx struct {
+	Action   string    `json:"action"`
+	Hash     string    `json:"hash"`
+	Revision int       `json:"revision,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// ArchivedLease is a lease of the deployment and the services the provider runs for it
+type ArchivedLease struct {
+	GSeq     uint32            `json:"gseq"`
+	OSeq     uint32            `json:"oseq"`
+	Provider string            `json:"provider"`
+	Price    string            `json:"price"`
+	HostURI  string            `json:"host-uri,omitempty"`
+	Services []ArchivedService `json:"services,omitempty"`
+	Created  time.Time         `json:"created"`
+	Closed   *time.Time        `json:"closed,omitempty"`
+}
+
+// ArchivedBid is a bid on an order of the deployment
+type ArchivedBid struct {
+	GSeq     uint32    `json:"gseq"`
+	OSeq     uint32    `json:"oseq"`
+	Provider string    `json:"provider"`
+	Price    string    `json:"price"`
+	State    string    `json:"state"`
+	Created  time.Time `json:"created"`
+}
+
+// ArchivedService is a service of a lease and the uris it is reachable on
+type ArchivedService struct {
+	Name string   `json:"name"`
+	URIs []string `json:"uris"`
+}
+
+// archivePath returns the directory holding the deployment archive
+func archivePath() string {
+	return path.Join(homePath, "deployments")
+}
+
+func archiveDir(owner string, dseq uint64) string {
+	return path.Join(archivePath(), fmt.Sprintf("%s.%d", owner, dseq))
+}
+
+// NewArchivedDeployment archives a new deployment with the sdl of the DeploymentData as its first revision
+func (c *Config) NewArchivedDeployment(dd *DeploymentData) (*ArchivedDeployment, error) {
+	if err := c.migrateArchive(); err != nil {
+		return nil, err
+	}
+	owner := dd.DeploymentID.Owner.String()
+	dir := archiveDir(owner, dd.DeploymentID.DSeq)
+	if _, err := os.Stat(dir); err == nil {
+		return nil, fmt.Errorf("deployment %s/%d is already archived in %s", owner, dd.DeploymentID.DSeq, dir)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	a := &ArchivedDeployment{
+		Owner:     owner,
+		DSeq:      dd.DeploymentID.DSeq,
+		Created:   now,
+		Updated:   now,
+		Revisions: []ArchivedRevision{},
+		Txs:       []ArchivedTx{},
+		Leases:    []ArchivedLease{},
+		dir:       dir,
+	}
+	if _, err := a.AddRevision(dd.SDLFile, dd.Version, ""); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// OpenArchivedDeployment reads the archive of a deployment
+func (c *Config) OpenArchivedDeployment(owner string, dseq uint64) (*ArchivedDeployment, error) {
+	if err := c.migrateArchive(); err != nil {
+		return nil, err
+	}
+	a, err := readArchivedDeployment(archiveDir(owner, dseq))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("deployment %s/%d isn't archived, was it created with `deploy create`?", owner, dseq)
+	}
+	return a, err
+}
+
+// ArchivedDeployments returns the archived deployments of the owner, or of every owner if it is empty, by dseq
+func (c *Config) ArchivedDeployments(owner string) ([]*ArchivedDeployment, error) {
+	if err := c.migrateArchive(); err != nil {
+		return nil, err
+	}
+	entries, err := ioutil.ReadDir(archivePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []*ArchivedDeployment
+	for _, e := range entries {
+		if !e.IsDir() || (owner != "" && !strings.HasPrefix(e.Name(), owner+".")) {
+			continue
+		}
+		a, err := readArchivedDeployment(path.Join(archivePath(), e.Name()))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].DSeq != out[j].DSeq {
+			return out[i].DSeq < out[j].DSeq
+		}
+		return out[i].Owner < out[j].Owner
+	})
+	return out, nil
+}
+
+func readArchivedDeployment(dir string) (*ArchivedDeployment, error) {
+	bz, err := ioutil.ReadFile(path.Join(dir, archiveMetadataFile))
+	if err != nil {
+		return nil, err
+	}
+	a := &ArchivedDeployment{dir: dir}
+	if err = json.Unmarshal(bz, a); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path.Join(dir, archiveMetadataFile), err)
+	}
+	return a, nil
+}
+
+// migrateArchive moves the `<owner>.<dseq>.yaml` files of older versions into archive directories
+func (c *Config) migrateArchive() error {
+	files, err := ioutil.ReadDir(archivePath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		parts := strings.Split(strings.TrimSuffix(f.Name(), ".yaml"), ".")
+		if f.IsDir() || path.Ext(f.Name()) != ".yaml" || len(parts) != 2 {
+			continue
+		}
+		dseq, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		file := path.Join(archivePath(), f.Name())
+		// older versions wrote the files with mode 644 in decimal, which the owner can't read
+		if f.Mode().Perm()&0400 == 0 {
+			if err = os.Chmod(file, 0644); err != nil {
+				logger.Error("couldn't make archived deployment readable, skipping it", "file", f.Name(), "err", err)
+				continue
+			}
+		}
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			logger.Error("couldn't read archived deployment, skipping it", "file", f.Name(), "err", err)
+			continue
+		}
+		// the version isn't known if the sdl no longer parses, keep the file anyway
+		var version []byte
+		if mani, err := sdlManifest(buf); err == nil {
+			version, _ = sdl.ManifestVersion(mani)
+		}
+
+		dir := archiveDir(parts[0], dseq)
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err = ioutil.WriteFile(path.Join(dir, revisionFile(1)), buf, 0644); err != nil {
+			return err
+		}
+		created := f.ModTime().UTC()
+		a := &ArchivedDeployment{
+			Owner:   parts[0],
+			DSeq:    dseq,
+			Created: created,
+			Revisions: []ArchivedRevision{{
+				Revision: 1,
+				Version:  hex.EncodeToString(version),
+				Created:  created,
+				Note:     "migrated from " + f.Name(),
+			}},
+			Txs:    []ArchivedTx{},
+			Leases: []ArchivedLease{},
+			dir:    dir,
+		}
+		if err = a.update(func() error { return nil }); err != nil {
+			return err
+		}
+		if err = os.Remove(file); err != nil {
+			return err
+		}
+		logger.Info("migrated archived deployment", "file", f.Name(), "dir", dir)
+	}
+	return nil
+}
+
+// Dir returns the directory of the archived deployment
+func (a *ArchivedDeployment) Dir() string {
+	return a.dir
+}
+
+// State returns active or closed
+func (a *ArchivedDeployment) State() string {
+	if a.Closed != nil {
+		return archiveStateClosed
+	}
+	return archiveStateActive
+}
+
+// Latest returns the latest revision
+func (a *ArchivedDeployment) Latest() ArchivedRevision {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.Revisions[len(a.Revisions)-1]
+}
+
+// Revision returns the revision and its sdl
+func (a *ArchivedDeployment) Revision(rev int) (ArchivedRevision, []byte, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, r := range a.Revisions {
+		if r.Revision == rev {
+			buf, err := ioutil.ReadFile(path.Join(a.dir, revisionFile(rev)))
+			return r, buf, err
+		}
+	}
+	return ArchivedRevision{}, nil, fmt.Errorf("deployment %s/%d has no revision %d, it has 1-%d",
+		a.Owner, a.DSeq, rev, len(a.Revisions))
+}
+
+func revisionFile(rev int) string {
+	return fmt.Sprintf("sdl.%d.yaml", rev)
+}
+
+// AddRevision stores the sdl as the next revision, returning its number
+func (a *ArchivedDeployment) AddRevision(buf, version []byte, note string) (rev int, err error) {
+	if a == nil {
+		return 0, nil
+	}
+	err = a.update(func() error {
+		rev = len(a.Revisions) + 1
+		if err := ioutil.WriteFile(path.Join(a.dir, revisionFile(rev)), buf, 0644); err != nil {
+			return err
+		}
+		a.Revisions = append(a.Revisions, ArchivedRevision{
+			Revision: rev,
+			Version:  hex.EncodeToString(version),
+			Created:  time.Now().UTC(),
+			Note:     note,
+		})
+		return nil
+	})
+	return rev, err
+}
+
+// AddTx records a transaction sent for the latest revision
+func (a *ArchivedDeployment) AddTx(action, hash string) error {
+	if a == nil {
+		return nil
+	}
+	return a.update(func() error {
+		a.Txs = append(a.Txs, ArchivedTx{
+			Action:   action,
+			Hash:     hash,
+			Revision: len(a.Revisions),
+			Time:     time.Now().UTC(),
+		})
+		return nil
+	})
+}
+
+// AddLease records a lease created for the deployment
+func (a *ArchivedDeployment) AddLease(id mtypes.LeaseID, price sdk.Coin) error {
+	if a == nil {
+		return nil
+	}
+	return a.update(func() error {
+		if a.lease(id) == nil {
+			a.Leases = append(a.Leases, ArchivedLease{
+				GSeq:     id.GSeq,
+				OSeq:     id.OSeq,
+				Provider: id.Provider.String(),
+				Price:    price.String(),
+				Created:  time.Now().UTC(),
+			})
+		}
+		return nil
+	})
+}
+
+// CloseLease records the lease as closed
+func (a *ArchivedDeployment) CloseLease(id mtypes.LeaseID) error {
+	if a == nil {
+		return nil
+	}
+	return a.update(func() error {
+		if l := a.lease(id); l != nil && l.Closed == nil {
+			now := time.Now().UTC()
+			l.Closed = &now
+		}
+		return nil
+	})
+}
+
+// SetLeaseServices records the provider uri of the lease and the uris of its services
+func (a *ArchivedDeployment) SetLeaseServices(id mtypes.LeaseID, hostURI string, status *cluster.LeaseStatus) error {
+	if a == nil {
+		return nil
+	}
+	return a.update(func() error {
+		l := a.lease(id)
+		if l == nil {
+			return nil
+		}
+		l.HostURI = hostURI
+		l.Services = make([]ArchivedService, 0, len(status.Services))
+		for _, s := range status.Services {
+			l.Services = append(l.Services, ArchivedService{Name: s.Name, URIs: s.URIs})
+		}
+		return nil
+	})
+}
+
+// AddBid records an open bid on an order of the deployment
+func (a *ArchivedDeployment) AddBid(id mtypes.BidID, price sdk.Coin) error {
+	if a == nil {
+		return nil
+	}
+	return a.update(func() error {
+		if a.bid(id) == nil {
+			a.Bids = append(a.Bids, ArchivedBid{
+				GSeq:     id.GSeq,
+				OSeq:     id.OSeq,
+				Provider: id.Provider.String(),
+				Price:    price.String(),
+				State:    mtypes.BidOpen.String(),
+				Created:  time.Now().UTC(),
+			})
+		}
+		return nil
+	})
+}
+
+// SetBidState records the state of a bid
+func (a *ArchivedDeployment) SetBidState(id mtypes.BidID, state mtypes.BidState) error {
+	if a == nil {
+		return nil
+	}
+	return a.update(func() error {
+		if b := a.bid(id); b != nil {
+			b.State = state.String()
+		}
+		return nil
+	})
+}
+
+// Close records the deployment and its open leases as closed
+func (a *ArchivedDeployment) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.update(func() error {
+		if a.Closed != nil {
+			return nil
+		}
+		now := time.Now().UTC()
+		a.Closed = &now
+		for i := range a.Leases {
+			if a.Leases[i].Closed == nil {
+				a.Leases[i].Closed = &now
+			}
+		}
+		return nil
+	})
+}
+
+// Snapshot returns a copy of the archived deployment that is safe to read while it is updated
+func (a *ArchivedDeployment) Snapshot() *ArchivedDeployment {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return &ArchivedDeployment{
+		Owner:     a.Owner,
+		DSeq:      a.DSeq,
+		Created:   a.Created,
+		Updated:   a.Updated,
+		Closed:    a.Closed,
+		Revisions: append([]ArchivedRevision(nil), a.Revisions...),
+		Txs:       append([]ArchivedTx(nil), a.Txs...),
+		Leases:    append([]ArchivedLease(nil), a.Leases...),
+		Bids:      append([]ArchivedBid(nil), a.Bids...),
+		dir:       a.dir,
+	}
+}
+
+func (a *ArchivedDeployment) bid(id mtypes.BidID) *ArchivedBid {
+	for i, b := range a.Bids {
+		if b.GSeq == id.GSeq && b.OSeq == id.OSeq && b.Provider == id.Provider.String() {
+			return &a.Bids[i]
+		}
+	}
+	return nil
+}
+
+// Remove deletes the archived deployment
+func (a *ArchivedDeployment) Remove() error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return os.RemoveAll(a.dir)
+}
+
+func (a *ArchivedDeployment) lease(id mtypes.LeaseID) *ArchivedLease {
+	for i, l := range a.Leases {
+		if l.GSeq == id.GSeq && l.OSeq == id.OSeq && l.Provider == id.Provider.String() {
+			return &a.Leases[i]
+		}
+	}
+	return nil
+}
+
+// update applies fn and saves the metadata, replacing the file so it is never partially written
+func (a *ArchivedDeployment) update(fn func() error) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := fn(); err != nil {
+		return err
+	}
+	a.Updated = time.Now().UTC()
+	bz, err := json.MarshalIndent(a, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path.Join(a.dir, "."+archiveMetadataFile)
+	if err = ioutil.WriteFile(tmp, bz, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path.Join(a.dir, archiveMetadataFile))
+}
@@ -16,41 +16,189 @@ limitations under the License.
 package cmd
 
 import (
-	"github.com/cosmos/cosmos-sdk/x/auth/types"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/auth"
 	"github.com/spf13/cobra"
 )
 
+// maxWatchErrors is how many balance queries in a row can fail with --watch before giving up
+const maxWatchErrors = 5
+
+var (
+	flagWatch    = "watch"
+	flagInterval = "interval"
+	flagTimeout  = "timeout"
+
+	// microDenoms are the denoms known to be a millionth of a display unit
+	microDenoms = map[string]string{
+		"uakt": "AKT",
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(balanceCmd())
+}
+
 // balanceCmd represents the balance command
-var balanceCmd = &cobra.Command{
-	Use:   "balance",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		cliCtx := config.CLICtx(config.NewTMClient())
-		accGetter := types.NewAccountRetriever(cliCtx)
-		acc, err := accGetter.GetAccount(config.GetAccAddress())
-		if err != nil {
-			return err
+func balanceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "balance [address]",
+		Short: "print the spendable coins of the configured key, or of the given address",
+		Long: `print the spendable coins of the configured key, or of the given address
+
+with --watch the balance is polled until coins arrive, e.g. while waiting on a faucet,
+for at most --timeout`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			addr := config.GetAccAddress()
+			if len(args) == 1 {
+				var err error
+				if addr, err = sdk.AccAddressFromBech32(args[0]); err != nil {
+					return err
+				}
+			}
+			if addr == nil {
+				return fmt.Errorf("no key loaded, create one with `deploy key-add`")
+			}
+
+			watch, err := cmd.Flags().GetBool(flagWatch)
+			if err != nil {
+				return err
+			}
+			interval, err := cmd.Flags().GetDuration(flagInterval)
+			if err != nil {
+				return err
+			}
+			timeout, err := cmd.Flags().GetDuration(flagTimeout)
+			if err != nil {
+				return err
+			}
+
+			bal, err := config.Balance(addr)
+			if watch && (err != nil || bal.Total.IsZero()) {
+				bal, err = watchBalance(addr, interval, timeout)
+			}
+			if err != nil {
+				return err
+			}
+			return bal.Print()
+		},
+	}
+	cmd.Flags().BoolP(flagWatch, "w", false, "poll until the account has a balance")
+	cmd.Flags().Duration(flagInterval, 2*time.Second, "how often to poll with --watch")
+	cmd.Flags().Duration(flagTimeout, 10*time.Minute, "how long to wait with --watch, 0 waits forever")
+	return cmd
+}
+
+// watchBalance polls the balance of the account until it has coins, giving up after
+// timeout, if it isn't 0, or once the balance couldn't be queried maxWatchErrors times in a row
+func watchBalance(addr sdk.AccAddress, interval, timeout time.Duration) (AccountBalance, error) {
+	fmt.Fprintf(os.Stderr, "waiting for coins to arrive at %s...\n", addr)
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for failed := 0; ; {
+		select {
+		case <-deadline:
+			return AccountBalance{}, fmt.Errorf("no coins arrived at %s after %s", addr, timeout)
+		case <-tick.C:
+		}
+		bal, err := config.Balance(addr)
+		switch {
+		case err != nil:
+			failed++
+			logger.Debug("couldn't query balance", "addr", addr, "err", err)
+			if failed == maxWatchErrors {
+				return bal, fmt.Errorf("giving up after %d failed balance queries: %w", failed, err)
+			}
+		case !bal.Total.IsZero():
+			return bal, nil
+		default:
+			failed = 0
 		}
-		return cliCtx.PrintOutput(acc)
-	},
+	}
 }
 
-func init() {
-	rootCmd.AddCommand(balanceCmd)
+// AccountBalance is the balance of an account
+type AccountBalance struct {
+	Address   sdk.AccAddress `json:"address"`
+	Spendable sdk.Coins      `json:"spendable"`
+	Total     sdk.Coins      `json:"total"`
+}
 
-	// Here you will define your flags and configuration settings.
+// Balance returns the spendable and total coins of the account
+func (c *Config) Balance(addr sdk.AccAddress) (AccountBalance, error) {
+	acc, err := auth.NewAccountRetriever(c.CLICtx(c.NewTMClient())).GetAccount(addr)
+	if err != nil {
+		return AccountBalance{}, err
+	}
+	return AccountBalance{
+		Address:   addr,
+		Spendable: acc.SpendableCoins(time.Now()),
+		Total:     acc.GetCoins(),
+	}, nil
+}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// balanceCmd.PersistentFlags().String("foo", "", "A help for foo")
+// Print prints the balance in the configured output format
+func (b AccountBalance) Print() error {
+	if config.Output == "json" {
+		bz, err := json.MarshalIndent(b, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(bz))
+		return nil
+	}
+
+	fmt.Printf("address: %s\n", b.Address)
+	if b.Total.IsZero() {
+		fmt.Println("no coins")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "DENOM\tSPENDABLE\tTOTAL\t")
+	for _, coin := range b.Total {
+		denom, total := formatCoin(coin)
+		_, spendable := formatCoin(sdk.NewCoin(coin.Denom, b.Spendable.AmountOf(coin.Denom)))
+		fmt.Fprintf(w, "%s\t%s\t%s\t\n", denom, spendable, total)
+	}
+	return w.Flush()
+}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// balanceCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+// formatCoin returns the display denom and amount of a coin, converting known micro
+// denoms to their whole unit and grouping the digits, e.g. 1234500000uakt is 1,234.5 AKT
+func formatCoin(coin sdk.Coin) (denom, amount string) {
+	digits, frac := coin.Amount.String(), ""
+	if display, ok := microDenoms[coin.Denom]; ok {
+		denom = display
+		if len(digits) < 7 {
+			digits = strings.Repeat("0", 7-len(digits)) + digits
+		}
+		digits, frac = digits[:len(digits)-6], strings.TrimRight(digits[len(digits)-6:], "0")
+	} else {
+		denom = coin.Denom
+	}
+
+	// group the whole part in thousands
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	if frac != "" {
+		b.WriteString("." + frac)
+	}
+	return denom, b.String()
 }
@@ -0,0 +1,295 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ovrclk/akash/x/market"
+	mquery "github.com/ovrclk/akash/x/market/query"
+	mtypes "github.com/ovrclk/akash/x/market/types"
+	pmodule "github.com/ovrclk/akash/x/provider"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(bidsCmd())
+}
+
+// bidsCmd represents the bids command
+func bidsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "bids [dseq]",
+		Short: "list the bids on each order of a deployment, which won and how their price compares with the sdl",
+		Long: `list the bids on each order of a deployment, which won and how their price compares with the sdl
+
+bids are queried from the chain. if it can't be queried, the bids recorded in the
+deployment archive while deploy watched the deployment are shown instead`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dseq, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid dseq %q: %w", args[0], err)
+			}
+			owner, err := ownerFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+
+			orders, err := chainOrderBids(owner, dseq)
+			if err != nil {
+				a, aerr := config.OpenArchivedDeployment(owner.String(), dseq)
+				if aerr != nil {
+					return err
+				}
+				logger.Info("couldn't query the chain, showing the bids recorded in the archive", "err", err)
+				if orders, err = archivedOrderBids(a); err != nil {
+					return err
+				}
+			}
+
+			if config.Output == "json" {
+				return printJSON(orders)
+			}
+			return printOrderBids(orders)
+		},
+	}
+	cmd.Flags().String(flagOwner, "", "owner of the deployment, defaults to the configured key")
+	return cmd
+}
+
+// orderBids are the bids on an order and the maximum price of its group
+type orderBids struct {
+	GSeq     uint32     `json:"gseq"`
+	OSeq     uint32     `json:"oseq"`
+	Group    string     `json:"group,omitempty"`
+	State    string     `json:"state,omitempty"`
+	MaxPrice string     `json:"max-price"`
+	Bids     []*bidInfo `json:"bids"`
+
+	max sdk.Coin
+}
+
+// bidInfo is a bid with its provider's attributes and whether it won the lease
+type bidInfo struct {
+	Provider   string            `json:"provider"`
+	Price      string            `json:"price"`
+	State      string            `json:"state"`
+	Won        bool              `json:"won"`
+	OfMax      string            `json:"of-max,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// chainOrderBids queries the orders of the deployment with their bids, leases and the attributes of the bidders
+func chainOrderBids(owner sdk.AccAddress, dseq uint64) ([]*orderBids, error) {
+	ctx := config.CLICtx(config.NewTMClient())
+	mclient := market.AppModuleBasic{}.GetQueryClient(ctx)
+	orders, err := mclient.Orders(mquery.OrderFilters{Owner: owner})
+	if err != nil {
+		return nil, err
+	}
+	bids, err := mclient.Bids(mquery.BidFilters{Owner: owner})
+	if err != nil {
+		return nil, err
+	}
+	leases, err := mclient.Leases(mquery.LeaseFilters{Owner: owner})
+	if err != nil {
+		return nil, err
+	}
+
+	won := make(map[string]bool)
+	for _, l := range leases {
+		if l.DSeq == dseq {
+			won[mtypes.BidID(l.LeaseID).String()] = true
+		}
+	}
+
+	pclient := pmodule.AppModuleBasic{}.GetQueryClient(ctx)
+	attrs := make(map[string]map[string]string)
+	providerAttributes := func(addr sdk.AccAddress) map[string]string {
+		if a, ok := attrs[addr.String()]; ok {
+			return a
+		}
+		// a provider that can't be queried is listed without attributes
+		if p, err := pclient.Provider(addr); err == nil {
+			attrs[addr.String()] = newProviderInfo(*p).Attributes
+		} else {
+			attrs[addr.String()] = nil
+		}
+		return attrs[addr.String()]
+	}
+
+	var out []*orderBids
+	for _, o := range orders {
+		if o.DSeq != dseq {
+			continue
+		}
+		ob := &orderBids{
+			GSeq:     o.GSeq,
+			OSeq:     o.OSeq,
+			Group:    o.Spec.Name,
+			State:    o.State.String(),
+			MaxPrice: o.Spec.Price().String(),
+			Bids:     []*bidInfo{},
+			max:      o.Spec.Price(),
+		}
+		for _, b := range bids {
+			if !o.OrderID.Equals(b.OrderID()) {
+				continue
+			}
+			ob.Bids = append(ob.Bids, &bidInfo{
+				Provider:   b.Provider.String(),
+				Price:      b.Price.String(),
+				State:      b.State.String(),
+				Won:        won[b.BidID.String()],
+				OfMax:      percentOf(b.Price, ob.max),
+				Attributes: providerAttributes(b.Provider),
+			})
+		}
+		sortBids(ob.Bids)
+		out = append(out, ob)
+	}
+	if out == nil {
+		return nil, fmt.Errorf("deployment %s/%d has no orders", owner, dseq)
+	}
+	return out, nil
+}
+
+// archivedOrderBids returns the bids recorded in the archive, with the maximum prices of the latest sdl revision
+func archivedOrderBids(a *ArchivedDeployment) ([]*orderBids, error) {
+	_, buf, err := a.Revision(a.Latest().Revision)
+	if err != nil {
+		return nil, err
+	}
+	dd, err := DeploymentDataFromSDL(buf)
+	if err != nil {
+		return nil, err
+	}
+	won := make(map[string]bool)
+	for _, l := range a.Leases {
+		won[fmt.Sprintf("%d/%d/%s", l.GSeq, l.OSeq, l.Provider)] = true
+	}
+
+	orders := make(map[[2]uint32]*orderBids)
+	var out []*orderBids
+	for _, b := range a.Bids {
+		key := [2]uint32{b.GSeq, b.OSeq}
+		ob, ok := orders[key]
+		if !ok {
+			ob = &orderBids{GSeq: b.GSeq, OSeq: b.OSeq, Bids: []*bidInfo{}}
+			// groups are numbered from 1 in the order of the sdl
+			if int(b.GSeq) <= len(dd.Groups) && b.GSeq > 0 {
+				g := dd.Groups[b.GSeq-1]
+				ob.Group, ob.max, ob.MaxPrice = g.Name, g.Price(), g.Price().String()
+			}
+			orders[key] = ob
+			out = append(out, ob)
+		}
+		price, err := sdk.ParseCoin(b.Price)
+		if err != nil {
+			return nil, err
+		}
+		ob.Bids = append(ob.Bids, &bidInfo{
+			Provider: b.Provider,
+			Price:    b.Price,
+			State:    b.State,
+			Won:      won[fmt.Sprintf("%d/%d/%s", b.GSeq, b.OSeq, b.Provider)],
+			OfMax:    percentOf(price, ob.max),
+		})
+	}
+	if out == nil {
+		return nil, fmt.Errorf("no bids recorded for deployment %s/%d", a.Owner, a.DSeq)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].GSeq != out[j].GSeq {
+			return out[i].GSeq < out[j].GSeq
+		}
+		return out[i].OSeq < out[j].OSeq
+	})
+	for _, ob := range out {
+		sortBids(ob.Bids)
+	}
+	return out, nil
+}
+
+// sortBids sorts the winner first, then by price
+// logPendingOrders logs the bids recorded for the orders of the deployment that have no lease yet
+func logPendingOrders(dd *DeploymentData) {
+	for _, o := range dd.PendingOrders() {
+		bids := dd.OrderBids(o)
+		if len(bids) == 0 {
+			logger.Info("order has no bids, the pricing may be too low or no provider has the resources", "gseq", o.GSeq, "oseq", o.OSeq)
+			continue
+		}
+		lowest := bids[0].Price
+		for _, b := range bids[1:] {
+			if b.Price.Denom == lowest.Denom && b.Price.IsLT(lowest) {
+				lowest = b.Price
+			}
+		}
+		logger.Info("order has bids but no lease, see `deploy bids` for more", "gseq", o.GSeq, "oseq", o.OSeq, "bids", len(bids), "lowest", lowest)
+	}
+}
+
+func sortBids(bids []*bidInfo) {
+	sort.SliceStable(bids, func(i, j int) bool {
+		if bids[i].Won != bids[j].Won {
+			return bids[i].Won
+		}
+		pi, erri := sdk.ParseCoin(bids[i].Price)
+		pj, errj := sdk.ParseCoin(bids[j].Price)
+		if erri != nil || errj != nil || pi.Denom != pj.Denom {
+			return bids[i].Price < bids[j].Price
+		}
+		return pi.IsLT(pj)
+	})
+}
+
+// percentOf returns the price as a percentage of the maximum, empty if they can't be compared
+func percentOf(price, max sdk.Coin) string {
+	if max.Denom == "" || price.Denom != max.Denom || !max.IsPositive() {
+		return ""
+	}
+	pct := price.Amount.ToDec().MulInt64(100).Quo(max.Amount.ToDec())
+	return pct.RoundInt().String() + "%"
+}
+
+func printOrderBids(orders []*orderBids) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for i, ob := range orders {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		desc := []string{}
+		if ob.Group != "" {
+			desc = append(desc, "group "+ob.Group)
+		}
+		if ob.MaxPrice != "" {
+			desc = append(desc, "max price "+ob.MaxPrice)
+		}
+		if ob.State != "" {
+			desc = append(desc, ob.State)
+		}
+		fmt.Fprintf(w, "order %d/%d (%s), %d bids\n", ob.GSeq, ob.OSeq, strings.Join(desc, ", "), len(ob.Bids))
+		if len(ob.Bids) == 0 {
+			continue
+		}
+		fmt.Fprintln(w, "\tPROVIDER\tPRICE\tOF MAX\tSTATE\tATTRIBUTES")
+		for _, b := range ob.Bids {
+			mark := ""
+			if b.Won {
+				mark = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", mark, b.Provider, b.Price, b.OfMax, b.State,
+				(&providerInfo{Attributes: b.Attributes}).formatAttributes())
+		}
+	}
+	if len(orders) > 0 {
+		fmt.Fprintln(w, "\n* won the lease")
+	}
+	return w.Flush()
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/cosmos/cosmos-sdk/x/auth"
+	authexported "github.com/cosmos/cosmos-sdk/x/auth/exported"
+	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+// check is a single named step of a diagnostic checklist
+type check struct {
+	name string
+	run  func() error
+	// hint tells the user how to fix a failure
+	hint string
+}
+
+// runChecks runs the checks in order, printing a pass/fail line for each, and returns the number that failed
+func runChecks(w io.Writer, checks []check) (failed int) {
+	for _, c := range checks {
+		if err := c.run(); err != nil {
+			failed++
+			fmt.Fprintf(w, "[FAIL] %s: %s\n", c.name, err)
+			if c.hint != "" {
+				fmt.Fprintf(w, "       hint: %s\n", c.hint)
+			}
+			continue
+		}
+		fmt.Fprintf(w, "[PASS] %s\n", c.name)
+	}
+	return failed
+}
+
+// setupChecks returns the checks for the selected network: each rpc endpoint
+// is reachable and on the right chain, the keyfile exists and unlocks, and the
+// account exists on chain with a balance
+func (c *Config) setupChecks() []check {
+	var checks []check
+	for _, addr := range c.Endpoints() {
+		var (
+			addr   = addr
+			status *ctypes.ResultStatus
+		)
+		checks = append(checks,
+			check{
+				name: fmt.Sprintf("rpc %s is reachable", addr),
+				run: func() error {
+					client, err := rpchttp.New(addr, "/websocket")
+					if err != nil {
+						return err
+					}
+					status, err = client.Status()
+					return err
+				},
+				hint: fmt.Sprintf("check the node is running and %s is correct, or fix it with `deploy config set %s`", flagRPCAddr, flagRPCAddr),
+			},
+			check{
+				name: fmt.Sprintf("rpc %s is on chain %s", addr, c.ChainID),
+				run: func() error {
+					switch {
+					case status == nil:
+						return fmt.Errorf("unreachable")
+					case status.NodeInfo.Network != c.ChainID:
+						return fmt.Errorf("node is on chain %s", status.NodeInfo.Network)
+					}
+					return nil
+				},
+				hint: fmt.Sprintf("point %s at a node of %s, or fix the chain-id with `deploy config set %s`", flagRPCAddr, c.ChainID, flagChainID),
+			},
+			check{
+				name: fmt.Sprintf("rpc %s is caught up", addr),
+				run: func() error {
+					switch {
+					case status == nil:
+						return fmt.Errorf("unreachable")
+					case status.SyncInfo.CatchingUp:
+						return fmt.Errorf("node is catching up, at height %d", status.SyncInfo.LatestBlockHeight)
+					}
+					return nil
+				},
+				hint: fmt.Sprintf("wait for the node to sync, or add a synced node to %s", flagRPCAddrs),
+			},
+		)
+	}
+
+	var acc authexported.Account
+	return append(checks,
+		check{
+			name: fmt.Sprintf("keyfile %s exists", c.KeyPath()),
+			run: func() error {
+				_, err := os.Stat(c.KeyPath())
+				return err
+			},
+			hint: fmt.Sprintf("create a key with `deploy key-add`, or point %s at an existing one", flagKeyfile),
+		},
+		check{
+			name: "keyfile unlocks with keypass",
+			run: func() error {
+				if c.keybase != nil {
+					return nil
+				}
+				return c.CreateKeybase()
+			},
+			hint: fmt.Sprintf("set the key's password with `deploy config set %s`", flagKeypass),
+		},
+		check{
+			name: "account exists on chain",
+			run: func() (err error) {
+				addr := c.GetAccAddress()
+				if addr == nil {
+					return fmt.Errorf("no key loaded")
+				}
+				acc, err = auth.NewAccountRetriever(c.CLICtx(c.NewTMClient())).GetAccount(addr)
+				return err
+			},
+			hint: "accounts only exist once they are sent tokens, fund `deploy address` from a faucet",
+		},
+		check{
+			name: "account has a balance",
+			run: func() error {
+				if acc == nil {
+					return fmt.Errorf("no account")
+				}
+				if acc.GetCoins().IsZero() {
+					return fmt.Errorf("account %s has no coins", acc.GetAddress())
+				}
+				return nil
+			},
+			hint: "fund `deploy address` from a faucet",
+		},
+	)
+}
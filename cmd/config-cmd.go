@@ -0,0 +1,329 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	flagResolved = "resolved"
+)
+
+func init() {
+	rootCmd.AddCommand(configCmd())
+}
+
+// configCmd represents the config command
+func configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "config",
+		Aliases: []string{"cfg"},
+		Short:   "view, edit and validate the configuration",
+		Annotations: map[string]string{
+			annotationLenient: "true",
+		},
+	}
+	cmd.AddCommand(
+		configShowCmd(),
+		configGetCmd(),
+		configSetCmd(),
+		configUnsetCmd(),
+		configValidateCmd(),
+		configEditCmd(),
+	)
+	return cmd
+}
+
+// resolvedSetting is a setting's effective value and where it came from
+type resolvedSetting struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+	Env    string `json:"env"`
+}
+
+func configShowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "print the config file, or with --resolved the effective settings for the selected network",
+		Long: `print the config file, or with --resolved the effective settings for the selected network
+
+each setting is resolved from, in order of precedence:
+  1. its flag, e.g. --rpc-addr
+  2. its environment variable, e.g. DEPLOY_RPC_ADDR
+  3. the selected network in the config file
+  4. the flag default
+
+the network itself is selected by --network, then DEPLOY_NETWORK, then the config's default-network`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resolved, err := cmd.Flags().GetBool(flagResolved)
+			if err != nil {
+				return err
+			}
+			if !resolved {
+				out, err := yaml.Marshal(config)
+				if err != nil {
+					return err
+				}
+				fmt.Print(string(out))
+				return nil
+			}
+
+			out := make([]resolvedSetting, 0, len(settings))
+			for _, s := range settings {
+				val := s.get(config)
+				if s.key == flagKeypass && val != "" {
+					val = "********"
+				}
+				out = append(out, resolvedSetting{
+					Key:    s.key,
+					Value:  val,
+					Source: config.Source(s.key),
+					Env:    envName(s.key),
+				})
+			}
+
+			if config.Output == "json" {
+				bz, err := json.MarshalIndent(out, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(bz))
+				return nil
+			}
+
+			fmt.Printf("network: %s\n", config.NetworkName)
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+			for _, s := range out {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", s.Key, s.Value, s.Source)
+			}
+			return w.Flush()
+		},
+	}
+	cmd.Flags().Bool(flagResolved, false, "show the effective value of every setting and where it came from")
+	return cmd
+}
+
+// selectedNetwork returns the selected network as stored in the config file
+func selectedNetwork() (*Network, error) {
+	n, ok := config.Networks[config.NetworkName]
+	if !ok {
+		return nil, fmt.Errorf("no network selected, add one with `deploy network add`")
+	}
+	return n, nil
+}
+
+func configGetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get [key]",
+		Short: "print a setting of the selected network from the config file, or with --resolved its effective value",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := lookupSetting(args[0])
+			if err != nil {
+				return err
+			}
+			resolved, err := cmd.Flags().GetBool(flagResolved)
+			if err != nil {
+				return err
+			}
+			if resolved {
+				fmt.Println(s.get(config))
+				return nil
+			}
+			n, err := selectedNetwork()
+			if err != nil {
+				return err
+			}
+			fmt.Println(s.get(&Config{Network: *n}))
+			return nil
+		},
+	}
+	cmd.Flags().Bool(flagResolved, false, "print the effective value after environment and flag overrides")
+	return cmd
+}
+
+func configSetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set [key] [value]",
+		Short: "set a setting on the selected network in the config file",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := lookupSetting(args[0])
+			if err != nil {
+				return err
+			}
+			n, err := selectedNetwork()
+			if err != nil {
+				return err
+			}
+			c := &Config{Network: *n}
+			if err = s.set(c, args[1]); err != nil {
+				return fmt.Errorf("invalid %s: %w", s.key, err)
+			}
+			*n = c.Network
+			return writeConfig(cmd, config)
+		},
+	}
+}
+
+func configUnsetCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "unset [key]",
+		Short: "remove a setting from the selected network in the config file, so its default is used",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, err := lookupSetting(args[0])
+			if err != nil {
+				return err
+			}
+			n, err := selectedNetwork()
+			if err != nil {
+				return err
+			}
+
+			// drop the setting from the network's yaml
+			bz, err := yaml.Marshal(n)
+			if err != nil {
+				return err
+			}
+			fields := make(map[string]interface{})
+			if err = yaml.Unmarshal(bz, &fields); err != nil {
+				return err
+			}
+			deleteYAMLPath(fields, s.filePath())
+			if bz, err = yaml.Marshal(fields); err != nil {
+				return err
+			}
+			unset := Network{}
+			if err = yaml.Unmarshal(bz, &unset); err != nil {
+				return err
+			}
+			*n = unset
+			return writeConfig(cmd, config)
+		},
+	}
+}
+
+// deleteYAMLPath deletes the value at the keys of nested yaml maps, and the maps it leaves empty
+func deleteYAMLPath(fields map[string]interface{}, keys []string) {
+	if len(keys) == 1 {
+		delete(fields, keys[0])
+		return
+	}
+	nested := make(map[string]interface{})
+	switch m := fields[keys[0]].(type) {
+	case map[string]interface{}:
+		nested = m
+	case map[interface{}]interface{}:
+		for k, v := range m {
+			nested[fmt.Sprint(k)] = v
+		}
+	default:
+		return
+	}
+	deleteYAMLPath(nested, keys[1:])
+	if len(nested) == 0 {
+		delete(fields, keys[0])
+		return
+	}
+	fields[keys[0]] = nested
+}
+
+func configValidateCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "check the selected network is usable: rpc reachability, chain-id, keyfile and account balance",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if config.ChainID == "" {
+				return fmt.Errorf("no network selected, add one with `deploy network add`")
+			}
+			fmt.Printf("validating network %q (%s)...\n", config.NetworkName, config.ChainID)
+			if failed := runChecks(os.Stdout, config.setupChecks()); failed > 0 {
+				return fmt.Errorf("%d checks failed", failed)
+			}
+			return nil
+		},
+	}
+}
+
+func configEditCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "edit",
+		Short: "open the config file in $EDITOR, saving it only if it is still valid",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// edit the file itself, it may not parse
+			before, err := ioutil.ReadFile(cfgPath)
+			if os.IsNotExist(err) {
+				before, err = yaml.Marshal(config)
+			}
+			if err != nil {
+				return err
+			}
+			tmp, err := ioutil.TempFile("", "deploy-config-*.yaml")
+			if err != nil {
+				return err
+			}
+			defer os.Remove(tmp.Name())
+			if _, err = tmp.Write(before); err != nil {
+				return err
+			}
+			if err = tmp.Close(); err != nil {
+				return err
+			}
+
+			editor := os.Getenv("EDITOR")
+			if editor == "" {
+				editor = "vi"
+			}
+			parts := strings.Fields(editor)
+			edit := exec.Command(parts[0], append(parts[1:], tmp.Name())...)
+			edit.Stdin, edit.Stdout, edit.Stderr = os.Stdin, os.Stdout, os.Stderr
+			if err = edit.Run(); err != nil {
+				return fmt.Errorf("running editor %s: %w", editor, err)
+			}
+
+			after, err := ioutil.ReadFile(tmp.Name())
+			if err != nil {
+				return err
+			}
+			if string(after) == string(before) {
+				fmt.Println("no changes")
+				return nil
+			}
+
+			// parse and check the edited config before saving it
+			cfg := &Config{}
+			if err = yaml.UnmarshalStrict(after, cfg); err != nil {
+				return fmt.Errorf("edited config is invalid, not saving: %w", err)
+			}
+			if _, ok := cfg.Networks[cfg.DefaultNetwork]; !ok && len(cfg.Networks) > 0 {
+				return fmt.Errorf("edited config is invalid, not saving: default-network %q not found", cfg.DefaultNetwork)
+			}
+			for name, n := range cfg.Networks {
+				c := &Config{Network: *n}
+				for _, s := range settings {
+					if v := s.get(c); v != "" {
+						if err = s.set(c, v); err != nil {
+							return fmt.Errorf("edited config is invalid, not saving: network %s: invalid %s: %w", name, s.key, err)
+						}
+					}
+				}
+			}
+			cfgErr = nil
+			return writeConfig(cmd, cfg)
+		},
+	}
+}
@@ -6,45 +6,81 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sync"
+	"time"
 
 	cctx "github.com/cosmos/cosmos-sdk/client/context"
 	"github.com/cosmos/cosmos-sdk/client/flags"
 	"github.com/cosmos/cosmos-sdk/codec"
 	"github.com/cosmos/cosmos-sdk/crypto/keys"
 	sdk "github.com/cosmos/cosmos-sdk/types"
-	"github.com/cosmos/cosmos-sdk/x/auth"
-	authclient "github.com/cosmos/cosmos-sdk/x/auth/client/utils"
 	"github.com/cosmos/go-bip39"
 	"github.com/ovrclk/akash/app"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
 	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
 	"gopkg.in/yaml.v2"
 )
 
 var (
-	akashPrefix = "akash"
-	defaultKey  = "default"
-	defaultPass = "12345678"
+	akashPrefix    = "akash"
+	defaultKey     = "default"
+	defaultPass    = "12345678"
+	defaultNetwork = "default"
 )
 
+// Network represents the settings for a single akash network
+type Network struct {
+	ChainID      string   `yaml:"chain-id" json:"chain-id"`
+	RPCAddr      string   `yaml:"rpc-addr" json:"rpc-addr"`
+	RPCAddrs     []string `yaml:"rpc-addrs,omitempty" json:"rpc-addrs,omitempty"`
+	Keyfile      string   `yaml:"keyfile" json:"keyfile"`
+	Keypass      string   `yaml:"keypass" json:"keypass"`
+	Bech32Prefix string   `yaml:"bech32-prefix,omitempty" json:"bech32-prefix,omitempty"`
+
+	GasPrices        string        `yaml:"gas-prices,omitempty" json:"gas-prices,omitempty"`
+	GasAdjustment    float64       `yaml:"gas-adjustment,omitempty" json:"gas-adjustment,omitempty"`
+	GasAdjustmentMax float64       `yaml:"gas-adjustment-max,omitempty" json:"gas-adjustment-max,omitempty"`
+	Gas              uint64        `yaml:"gas,omitempty" json:"gas,omitempty"`
+	Fees             string        `yaml:"fees,omitempty" json:"fees,omitempty"`
+	BroadcastMode    string        `yaml:"broadcast-mode,omitempty" json:"broadcast-mode,omitempty"`
+	TxTimeout        time.Duration `yaml:"tx-timeout,omitempty" json:"tx-timeout,omitempty"`
+
+	Output string `yaml:"output,omitempty" json:"output,omitempty"`
+
+	Providers      ProviderFilter `yaml:"providers,omitempty" json:"providers,omitempty"`
+	ProviderPolicy string         `yaml:"provider-policy,omitempty" json:"provider-policy,omitempty"`
+}
+
 // Config represents the application configuration
 type Config struct {
-	ChainID string `yaml:"chain-id" json:"chain-id"`
-	RPCAddr string `yaml:"rpc-addr" json:"rpc-addr"`
-	Keyfile string `yaml:"keyfile" json:"keyfile"`
-	Keypass string `yaml:"keypass" json:"keypass"`
+	DefaultNetwork string              `yaml:"default-network" json:"default-network"`
+	Networks       map[string]*Network `yaml:"networks" json:"networks"`
+
+	// Network is a copy of the network selected by --network or DefaultNetwork
+	Network `yaml:"-" json:"-"`
+	// NetworkName is the name of the selected network
+	NetworkName string `yaml:"-" json:"-"`
 
-	gasAdj    float64
 	gasPrices sdk.DecCoins
+	fees      sdk.Coins
+
+	// sources records where each setting was resolved from
+	sources map[string]string
+
+	txqMu    sync.Mutex
+	txQueues map[string]*txQueue
+
+	rpcMu sync.Mutex
+	rpc   *rpcPool
 
 	keybase keys.Keybase
 	address sdk.AccAddress
-	Amino   *codec.Codec
+	Amino   *codec.Codec `yaml:"-" json:"-"`
 }
 
 // CLICtx returns the CLICtx object with some defaults set
-func (c *Config) CLICtx(client *rpchttp.HTTP) cctx.CLIContext {
+func (c *Config) CLICtx(client rpcclient.Client) cctx.CLIContext {
 	return cctx.CLIContext{
 		FromAddress:   c.address,
 		Client:        client,
@@ -53,9 +89,9 @@ func (c *Config) CLICtx(client *rpchttp.HTTP) cctx.CLIContext {
 		NodeURI:       c.RPCAddr,
 		Input:         os.Stdin,
 		Output:        os.Stdout,
-		OutputFormat:  "json",
+		OutputFormat:  c.Output,
 		From:          defaultKey,
-		BroadcastMode: "sync",
+		BroadcastMode: c.BroadcastMode,
 		FromName:      defaultKey,
 		Codec:         c.Amino,
 		TrustNode:     true,
@@ -67,15 +103,23 @@ func (c *Config) CLICtx(client *rpchttp.HTTP) cctx.CLIContext {
 	}
 }
 
+// Prefix returns the bech32 account prefix for the selected network
+func (c *Config) Prefix() string {
+	if c.Bech32Prefix == "" {
+		return akashPrefix
+	}
+	return c.Bech32Prefix
+}
+
 // GetAccAddress returns the deployer account address
 func (c *Config) GetAccAddress() sdk.AccAddress {
 	if c.address != nil {
 		return c.address
 	}
 
-	// ensure we are returning akash addresses
+	// ensure we are returning addresses with the network's prefix
 	sdkConf := sdk.GetConfig()
-	sdkConf.SetBech32PrefixForAccount(akashPrefix, akashPrefix+"pub")
+	sdkConf.SetBech32PrefixForAccount(c.Prefix(), c.Prefix()+"pub")
 
 	if c.keybase != nil {
 		k, _ := c.keybase.Get(defaultKey)
@@ -84,8 +128,11 @@ func (c *Config) GetAccAddress() sdk.AccAddress {
 	return nil
 }
 
-// initConfig reads in config file and ENV variables if set.
-func initConfig(cmd *cobra.Command) error {
+// initConfig reads in the config file, selects the network and applies the
+// DEPLOY_* environment variable and flag overrides on top of it. When lenient
+// is set an invalid network is reported instead of exiting, when newNetwork is
+// set the selected network may not exist yet
+func initConfig(cmd *cobra.Command, lenient, newNetwork bool) error {
 	home, err := cmd.PersistentFlags().GetString(flags.FlagHome)
 	if err != nil {
 		return err
@@ -94,34 +141,103 @@ func initConfig(cmd *cobra.Command) error {
 	config = &Config{}
 	cfgPath = path.Join(home, "config.yaml")
 	if _, err := os.Stat(cfgPath); err == nil {
-		viper.SetConfigFile(cfgPath)
-		if err := viper.ReadInConfig(); err == nil {
-			// read the config file bytes
-			file, err := ioutil.ReadFile(viper.ConfigFileUsed())
-			if err != nil {
-				fmt.Println("Error reading file:", err)
-				os.Exit(1)
-			}
+		// read the config file bytes
+		file, err := ioutil.ReadFile(cfgPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error reading file:", err)
+			os.Exit(1)
+		}
 
-			// unmarshall them into the struct
-			err = yaml.Unmarshal(file, config)
-			if err != nil {
-				fmt.Println("Error unmarshalling config:", err)
+		// unmarshall them into the struct and move single network configs into the networks map
+		if cfgErr = yaml.Unmarshal(file, config); cfgErr != nil {
+			if !lenient {
+				fmt.Fprintln(os.Stderr, "Error unmarshalling config:", cfgErr)
 				os.Exit(1)
 			}
+			fmt.Fprintln(os.Stderr, "Warning, invalid config file:", cfgErr)
+			config = &Config{}
+		} else if err = migrateConfig(cmd, file, config); err != nil {
+			fmt.Fprintln(os.Stderr, "Error migrating config:", err)
+			os.Exit(1)
+		}
+	}
 
-			// ensure config has []*relayer.Chain used for all chain operations
-			err = validateConfig(config)
-			if err != nil {
-				fmt.Println("Error parsing chain config:", err)
-				os.Exit(1)
-			}
+	// select the network to use for this command
+	network, err := cmd.PersistentFlags().GetString(flagNetwork)
+	if err != nil {
+		return err
+	}
+	if env, ok := os.LookupEnv(envName(flagNetwork)); ok && !cmd.PersistentFlags().Changed(flagNetwork) {
+		network = env
+	}
+	if err = config.UseNetwork(network); err != nil {
+		if !newNetwork {
+			return err
 		}
-	} else if os.IsNotExist(err) {
-		// If the config file doesn't exist, just log and exit
-		fmt.Printf("config file %s doesn't exist\n", cfgPath)
+		// the command creates the network, there is nothing to validate yet
+		return config.resolveSettings(cmd)
+	}
+
+	// apply the environment and flag overrides
+	if err = config.resolveSettings(cmd); err != nil {
+		return err
+	}
+
+	// If there is no network to talk to, just log and exit
+	if config.ChainID == "" && config.RPCAddr == "" {
+		fmt.Fprintf(os.Stderr, "config file %s doesn't exist or has no networks\n", cfgPath)
+		return nil
+	}
+
+	// ensure config has everything needed for chain operations
+	err = validateConfig(config)
+	if err != nil && lenient {
+		fmt.Fprintln(os.Stderr, "Warning, invalid chain config:", err)
+		return nil
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "Error parsing chain config:", err)
+		os.Exit(1)
+	}
+	return nil
+}
+
+// migrateConfig moves the chain settings of a config file written before
+// named networks were introduced into a network called "default"
+func migrateConfig(cmd *cobra.Command, file []byte, c *Config) error {
+	if len(c.Networks) > 0 {
+		return nil
+	}
+
+	// the old config format is a single network at the top level
+	legacy := &Network{}
+	if err := yaml.Unmarshal(file, legacy); err != nil {
+		return err
+	}
+	if legacy.ChainID == "" {
 		return nil
 	}
+
+	fmt.Fprintf(os.Stderr, "migrating config %s to network %q...\n", cfgPath, defaultNetwork)
+	c.DefaultNetwork = defaultNetwork
+	c.Networks = map[string]*Network{defaultNetwork: legacy}
+	return writeConfig(cmd, c)
+}
+
+// UseNetwork selects the named network, or the default network if name is empty
+func (c *Config) UseNetwork(name string) error {
+	if name == "" {
+		// nothing to select until a network is added
+		if len(c.Networks) == 0 {
+			return nil
+		}
+		name = c.DefaultNetwork
+	}
+	n, ok := c.Networks[name]
+	if !ok {
+		return fmt.Errorf("network %q not found in config %s", name, cfgPath)
+	}
+	c.Network = *n
+	c.NetworkName = name
 	return nil
 }
 
@@ -131,14 +247,16 @@ func validateConfig(c *Config) (err error) {
 	c.Amino = app.MakeCodec()
 
 	// If we are unable to create a new RPC client (rpc-addr doesn't parse) return err
-	if _, err = rpchttp.New(c.RPCAddr, "/websocket"); err != nil {
-		return
+	for _, addr := range c.Endpoints() {
+		if _, err = rpchttp.New(addr, "/websocket"); err != nil {
+			return
+		}
 	}
 
 	// Warn if priv key specified and not exist at given path
-	keypath := path.Join(homePath, c.Keyfile)
+	keypath := c.KeyPath()
 	if _, err = os.Stat(keypath); os.IsNotExist(err) {
-		fmt.Printf("Private key specified in the config file doesn't exist: %s\n", keypath)
+		fmt.Fprintf(os.Stderr, "Private key specified in the config file doesn't exist: %s\n", keypath)
 		return nil
 	}
 
@@ -153,18 +271,18 @@ func validateConfig(c *Config) (err error) {
 	return
 }
 
-// NewTMClient returns a new tendermint RPC client from the config
-// NOTE: there shouldn't be errors here because we already check them
-// in validateConfig
-func (c *Config) NewTMClient() *rpchttp.HTTP {
-	out, _ := rpchttp.New(c.RPCAddr, "/websocket")
-	return out
+// KeyPath returns the path of the keyfile, relative to the home directory unless it is absolute
+func (c *Config) KeyPath() string {
+	if filepath.IsAbs(c.Keyfile) {
+		return c.Keyfile
+	}
+	return path.Join(homePath, c.Keyfile)
 }
 
 // CreateKeybase returns the
 func (c *Config) CreateKeybase() (err error) {
 	kb := keys.NewInMemory()
-	kf, err := os.Open(path.Join(homePath, c.Keyfile))
+	kf, err := os.Open(c.KeyPath())
 	if err != nil {
 		return
 	}
@@ -172,14 +290,16 @@ func (c *Config) CreateKeybase() (err error) {
 	if err != nil {
 		return
 	}
-	err = kb.ImportPrivKey(defaultKey, string(byt), c.Keypass)
+	if err = kb.ImportPrivKey(defaultKey, string(byt), c.Keypass); err != nil {
+		return
+	}
 	c.keybase = kb
 	return
 }
 
 // CreateKey creates a new private key
 func (c *Config) CreateKey() (err error) {
-	kp := path.Join(homePath, c.Keyfile)
+	kp := c.KeyPath()
 
 	if _, err := os.Stat(kp); !os.IsNotExist(err) {
 		return fmt.Errorf("keyfile %s already exists", kp)
@@ -210,62 +330,6 @@ func (c *Config) CreateKey() (err error) {
 	return ioutil.WriteFile(kp, []byte(armor), 0644)
 }
 
-// SendMsgs sends given sdk messages
-func (c *Config) SendMsgs(datagrams []sdk.Msg) (res sdk.TxResponse, err error) {
-	// validate basic all the msgs
-	for _, msg := range datagrams {
-		if err := msg.ValidateBasic(); err != nil {
-			return res, err
-		}
-	}
-
-	var out []byte
-	if out, err = c.BuildAndSignTx(datagrams); err != nil {
-		return res, err
-	}
-	return c.BroadcastTxCommit(out)
-}
-
-// BuildAndSignTx takes messages and builds, signs and marshals a sdk.Tx to prepare it for broadcast
-func (c *Config) BuildAndSignTx(msgs []sdk.Msg) ([]byte, error) {
-	// Fetch account and sequence numbers for the account
-	var txBldr auth.TxBuilder
-	ctx := c.CLICtx(c.NewTMClient())
-	acc, err := auth.NewAccountRetriever(ctx).GetAccount(c.GetAccAddress())
-	if err != nil {
-		return nil, err
-	}
-
-	// Create the transaction builder with some sane defaults
-	// TODO: add some debug output?
-	txBldr = auth.NewTxBuilder(
-		auth.DefaultTxEncoder(c.Amino),
-		acc.GetAccountNumber(),
-		acc.GetSequence(),
-		200000,
-		c.gasAdj,
-		true,
-		c.ChainID,
-		"",
-		sdk.NewCoins(),
-		c.gasPrices,
-	).WithKeybase(c.keybase)
-
-	// Estimate the gas
-	if txBldr, err = authclient.EnrichWithGas(txBldr, ctx, msgs); err != nil {
-		return nil, err
-	}
-
-	// Return nil or the signature error
-	return txBldr.BuildAndSign(defaultKey, c.Keypass, msgs)
-}
-
-// BroadcastTxCommit takes the marshaled transaction bytes and broadcasts them
-func (c *Config) BroadcastTxCommit(txBytes []byte) (sdk.TxResponse, error) {
-	// TODO: add some debug output?
-	return c.CLICtx(c.NewTMClient()).BroadcastTxCommit(txBytes)
-}
-
 // BlockHeight returns the current block height from the configured client
 func (c *Config) BlockHeight() (uint64, error) {
 	status, err := c.NewTMClient().Status()
@@ -275,7 +339,36 @@ func (c *Config) BlockHeight() (uint64, error) {
 	return uint64(status.SyncInfo.LatestBlockHeight), nil
 }
 
+// AverageBlockTime returns the average time between the last n blocks
+func (c *Config) AverageBlockTime(n int64) (time.Duration, error) {
+	client := c.NewTMClient()
+	latest, err := client.Block(nil)
+	if err != nil {
+		return 0, err
+	}
+	height := latest.Block.Height - n
+	if height < 1 {
+		height = 1
+	}
+	if n = latest.Block.Height - height; n < 1 {
+		return 0, fmt.Errorf("chain has too few blocks to measure block time")
+	}
+	first, err := client.Block(&height)
+	if err != nil {
+		return 0, err
+	}
+	d := latest.Block.Time.Sub(first.Block.Time) / time.Duration(n)
+	if d <= 0 {
+		return 0, fmt.Errorf("blocks %d to %d have no time between them", height, latest.Block.Height)
+	}
+	return d, nil
+}
+
 func writeConfig(cmd *cobra.Command, cfg *Config) (err error) {
+	// don't replace a config file that couldn't be read with the empty config
+	if cfgErr != nil {
+		return fmt.Errorf("config file %s is invalid, fix it with `deploy config edit`: %w", cfgPath, cfgErr)
+	}
 	if err = os.MkdirAll(filepath.Dir(cfgPath), os.ModePerm); err != nil {
 		return
 	}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// execute runs the deploy command with the args, resetting every flag afterwards
+func execute(args ...string) error {
+	defer resetFlags(rootCmd)
+	rootCmd.SetArgs(args)
+	return rootCmd.Execute()
+}
+
+// resetFlags sets the flags of the command and its subcommands back to their defaults
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			_ = s.Replace(nil)
+		} else {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.PersistentFlags().VisitAll(reset)
+	cmd.Flags().VisitAll(reset)
+	for _, c := range cmd.Commands() {
+		resetFlags(c)
+	}
+}
+
+func TestCreateNamedNetwork(t *testing.T) {
+	home, err := ioutil.TempDir("", "deploy-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	for _, args := range [][]string{
+		{"init", "--network", "staging", "test-1", "http://localhost:26657"},
+		{"network", "add", "stage", "test-2", "http://localhost:36657", "-n", "stage"},
+	} {
+		if err = execute(append([]string{"--home", home}, args...)...); err != nil {
+			t.Fatalf("deploy %v: %v", args, err)
+		}
+	}
+
+	file, err := ioutil.ReadFile(filepath.Join(home, "config.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Config{}
+	if err = yaml.Unmarshal(file, c); err != nil {
+		t.Fatal(err)
+	}
+	if c.DefaultNetwork != "staging" {
+		t.Errorf("expected default network staging, got %q", c.DefaultNetwork)
+	}
+	for name, chainID := range map[string]string{"staging": "test-1", "stage": "test-2"} {
+		if n, ok := c.Networks[name]; !ok || n.ChainID != chainID {
+			t.Errorf("expected network %s with chain id %s, got %+v", name, chainID, n)
+		}
+	}
+}
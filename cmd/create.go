@@ -17,9 +17,6 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path"
 	"strings"
 	"time"
 
@@ -28,18 +25,16 @@ import (
 	"github.com/ovrclk/akash/provider/cluster"
 	"github.com/ovrclk/akash/provider/gateway"
 	dcli "github.com/ovrclk/akash/x/deployment/client/cli"
+	dtypes "github.com/ovrclk/akash/x/deployment/types"
+	"github.com/ovrclk/akash/x/market"
+	mquery "github.com/ovrclk/akash/x/market/query"
+	mtypes "github.com/ovrclk/akash/x/market/types"
 	pmodule "github.com/ovrclk/akash/x/provider"
 	pquery "github.com/ovrclk/akash/x/provider/query"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	"golang.org/x/sync/errgroup"
 )
 
-var (
-	flagGasAdj    = "gas-adjustment"
-	flagGasPrices = "gas-prices"
-)
-
 func init() {
 	rootCmd.AddCommand(createCmd())
 }
@@ -51,7 +46,7 @@ func createCmd() *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		Short: "Create a deployment to be managed by the deploy application",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			if err := config.SetGasOnConfigFromFlags(cmd); err != nil {
+			if err := config.SetGasOnConfig(); err != nil {
 				return err
 			}
 
@@ -61,6 +56,11 @@ func createCmd() *cobra.Command {
 				return err
 			}
 
+			// Store the sdl and metadata of the deployment in the archive
+			if dd.Archive, err = config.NewArchivedDeployment(dd); err != nil {
+				return err
+			}
+
 			ctx, cancel := context.WithCancel(context.Background())
 			group, _ := errgroup.WithContext(ctx)
 
@@ -72,25 +72,19 @@ func createCmd() *cobra.Command {
 				return err
 			})
 
-			// Store the deployment manifest in the archive
-			group.Go(func() error {
-				if err = config.CreateDeploymentFileInArchive(dd); err != nil {
-					log.Error("error updating archive", err)
-				}
-				return err
-			})
-
 			// Send the deployment creation transaction
 			group.Go(func() error {
 				if err = config.TxCreateDeployment(dd); err != nil {
 					log.Error("error creating deployment", err)
+					// nothing will happen on chain, stop listening
+					cancel()
 				}
 				return err
 			})
 
 			// Wait for the leases to be created and then start polling the provider for service availability
 			group.Go(func() error {
-				if err = config.WaitForLeasesAndPollService(dd, cancel); err != nil {
+				if err = config.WaitForLeasesAndPollService(ctx, dd, cancel); err != nil {
 					log.Error("error listening for service", err)
 				}
 				return err
@@ -100,46 +94,57 @@ func createCmd() *cobra.Command {
 		},
 	}
 	dcli.AddDeploymentIDFlags(cmd.Flags())
-	rootCmd.PersistentFlags().Float64P(flagGasAdj, "a", 1.0, "gas adjustment for transactions. if your transactions are failing due to out of gas errors increase this number")
-	rootCmd.PersistentFlags().StringP(flagGasPrices, "p", "0.025akash", "price for gas")
-	if err := viper.BindPFlag(flagGasAdj, cmd.Flags().Lookup(flagGasAdj)); err != nil {
-		panic(err)
-	}
-	if err := viper.BindPFlag(flagGasPrices, cmd.Flags().Lookup(flagGasPrices)); err != nil {
-		panic(err)
-	}
+	AddSDLTemplateFlags(cmd.Flags())
 	return cmd
 }
 
-// SetGasOnConfigFromFlags pulls the gas prices and gas adj variables from the flags and sets them on the global config object
-func (c *Config) SetGasOnConfigFromFlags(cmd *cobra.Command) error {
-	gasAdj, err := cmd.Flags().GetFloat64(flagGasAdj)
+// SetGasOnConfig parses the resolved gas prices and fees and sets them on the global config object
+func (c *Config) SetGasOnConfig() error {
+	fees, err := sdk.ParseCoins(c.Fees)
 	if err != nil {
 		return err
 	}
-	gp, err := cmd.Flags().GetString(flagGasPrices)
+	gasPr, err := sdk.ParseDecCoins(c.GasPrices)
 	if err != nil {
 		return err
 	}
-	gasPr, err := sdk.ParseDecCoins(gp)
-	if err != nil {
-		return err
+
+	// fees replace gas prices set with a lower precedence, e.g. --fees over gas-prices
+	// in the config file, but both can't be asked for at the same level
+	if !fees.IsZero() && !gasPr.IsZero() {
+		feesRank, pricesRank := sourceRank(c.Source(flagFees)), sourceRank(c.Source(flagGasPrices))
+		switch {
+		case feesRank == pricesRank:
+			return fmt.Errorf("cannot provide both %s (%s) and %s (%s)", flagFees, c.Source(flagFees), flagGasPrices, c.Source(flagGasPrices))
+		case feesRank > pricesRank:
+			gasPr = sdk.DecCoins{}
+		default:
+			fees = sdk.Coins{}
+		}
+	}
+
+	if c.GasAdjustmentMax < c.GasAdjustment {
+		c.GasAdjustmentMax = c.GasAdjustment
 	}
 	c.gasPrices = gasPr
-	c.gasAdj = gasAdj
+	c.fees = fees
 	return nil
 }
 
 // WaitForLeasesAndPollService waits for
-func (c *Config) WaitForLeasesAndPollService(dd *DeploymentData, cancel context.CancelFunc) error {
+func (c *Config) WaitForLeasesAndPollService(ctx context.Context, dd *DeploymentData, cancel context.CancelFunc) error {
 	log := logger
 	pclient := pmodule.AppModuleBasic{}.GetQueryClient(config.CLICtx(config.NewTMClient()))
 	timeout := time.After(90 * time.Second)
 	tick := time.Tick(500 * time.Millisecond)
 	for {
 		select {
+		case <-ctx.Done():
+			return nil
 		case <-timeout:
-			log.Info("timed out (90s) listening for deployment to be available")
+			log.Info("timed out (90s) listening for deployment to be available, see `deploy events` for more", "dseq", dd.DeploymentID.DSeq)
+			logPendingOrders(dd)
+			printDeploymentEvents(dd.Leases())
 			cancel()
 			return nil
 		case <-tick:
@@ -176,6 +181,10 @@ func (c *Config) WaitForLeasesAndPollService(dd *DeploymentData, cancel context.
 						return fmt.Errorf("error querying lease status: %w", err)
 					}
 
+					if err := dd.Archive.SetLeaseServices(l, p.HostURI, ls); err != nil {
+						log.Error("error updating archive", "err", err)
+					}
+
 					for _, s := range ls.Services {
 						// TODO: Much better logging/ux could be put in here: waiting, timeouts etc...
 						if s.Available == s.Total {
@@ -190,16 +199,22 @@ func (c *Config) WaitForLeasesAndPollService(dd *DeploymentData, cancel context.
 	}
 }
 
-// CreateDeploymentFileInArchive creates the deployment file in the `$HOME/.akash-deploy/deployments/` folder
-func (c *Config) CreateDeploymentFileInArchive(dd *DeploymentData) error {
-	fileName := fmt.Sprintf("%s.%d.yaml", dd.DeploymentID.Owner, dd.DeploymentID.DSeq)
-	depDir := path.Join(homePath, "deployments")
-	if _, err := os.Stat(depDir); os.IsNotExist(err) {
-		if err = os.MkdirAll(depDir, 0777); err != nil {
-			return err
+// ActiveLeases returns the active leases of the deployment
+func (c *Config) ActiveLeases(id dtypes.DeploymentID) ([]mtypes.LeaseID, error) {
+	leases, err := market.AppModuleBasic{}.GetQueryClient(c.CLICtx(c.NewTMClient())).Leases(mquery.LeaseFilters{
+		Owner: id.Owner,
+		State: mtypes.LeaseActive,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var out []mtypes.LeaseID
+	for _, l := range leases {
+		if l.LeaseID.DSeq == id.DSeq {
+			out = append(out, l.LeaseID)
 		}
 	}
-	return ioutil.WriteFile(path.Join(depDir, fileName), dd.SDLFile, 644)
+	return out, nil
 }
 
 // TxCreateDeployment takes DeploymentData and creates the specified deployment
@@ -213,11 +228,18 @@ func (c *Config) TxCreateDeployment(dd *DeploymentData) (err error) {
 		"dseq", dd.DeploymentID.DSeq,
 	)
 
-	if err != nil || res.Code != 0 {
-		log.Error("tx failed")
+	if err != nil {
+		log.Error("tx failed", "log", res.RawLog)
+		// the deployment doesn't exist, don't keep it in the archive
+		if rerr := dd.Archive.Remove(); rerr != nil {
+			log.Error("error updating archive", "err", rerr)
+		}
 		return err
 	}
 
 	log.Info("tx sent successfully")
+	if err = dd.Archive.AddTx("create-deployment", res.TxHash); err != nil {
+		log.Error("error updating archive", "err", err)
+	}
 	return nil
 }
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"io/ioutil"
 	"sync"
 
 	sdk "github.com/cosmos/cosmos-sdk/types"
@@ -22,11 +21,34 @@ type DeploymentData struct {
 	DeploymentID dtypes.DeploymentID
 	OrderID      []mtypes.OrderID
 	LeaseID      []mtypes.LeaseID
+	Bid          []mtypes.Bid
 	Version      []byte
+	// Providers are the providers allowed or denied by the sdl
+	Providers ProviderFilter
+	// Archive is nil when the deployment isn't archived
+	Archive *ArchivedDeployment
+
+	// rejections counts the leases closed by RejectLease by group
+	rejections map[uint32]int
+	// checked caches the result of CheckProvider by provider address
+	checked map[string]error
 
 	sync.RWMutex
 }
 
+// MsgUpdate constructor for MsgUpdateDeployment
+func (dd *DeploymentData) MsgUpdate() dtypes.MsgUpdateDeployment {
+	msg := dtypes.MsgUpdateDeployment{
+		ID:      dd.DeploymentID,
+		Groups:  make([]dtypes.GroupSpec, 0, len(dd.Groups)),
+		Version: dd.Version,
+	}
+	for _, group := range dd.Groups {
+		msg.Groups = append(msg.Groups, *group)
+	}
+	return msg
+}
+
 // MsgCreate constructor for MsgCreateDeployment
 func (dd *DeploymentData) MsgCreate() dtypes.MsgCreateDeployment {
 	// Create the deployment message
@@ -104,6 +126,59 @@ func (dd *DeploymentData) RemoveLease(order mtypes.LeaseID) {
 	dd.LeaseID = out
 }
 
+// OrderBids returns a copy of the bids tracked for the order
+func (dd *DeploymentData) OrderBids(order mtypes.OrderID) []mtypes.Bid {
+	dd.RLock()
+	defer dd.RUnlock()
+	var out []mtypes.Bid
+	for _, b := range dd.Bid {
+		if b.BidID.OrderID().Equals(order) {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// PendingOrders returns the orders tracked that have no lease
+func (dd *DeploymentData) PendingOrders() []mtypes.OrderID {
+	dd.RLock()
+	defer dd.RUnlock()
+	var out []mtypes.OrderID
+	for _, o := range dd.OrderID {
+		leased := false
+		for _, l := range dd.LeaseID {
+			leased = leased || l.OrderID().Equals(o)
+		}
+		if !leased {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// AddBid adds an open bid for tracking
+func (dd *DeploymentData) AddBid(id mtypes.BidID, price sdk.Coin) {
+	dd.Lock()
+	defer dd.Unlock()
+	for _, b := range dd.Bid {
+		if b.BidID.Equals(id) {
+			return
+		}
+	}
+	dd.Bid = append(dd.Bid, mtypes.Bid{BidID: id, State: mtypes.BidOpen, Price: price})
+}
+
+// SetBidState sets the state of a tracked bid
+func (dd *DeploymentData) SetBidState(id mtypes.BidID, state mtypes.BidState) {
+	dd.Lock()
+	defer dd.Unlock()
+	for i, b := range dd.Bid {
+		if b.BidID.Equals(id) {
+			dd.Bid[i].State = state
+		}
+	}
+}
+
 // NewDeploymentDataFromConfig returns all the deployment data that can be gleaned from the config file
 func NewDeploymentDataFromConfig() *DeploymentData {
 	return &DeploymentData{
@@ -113,12 +188,30 @@ func NewDeploymentDataFromConfig() *DeploymentData {
 	}
 }
 
-// NewDeploymentData returns a DeploymentData struct initialized from a file and flags
+// NewDeploymentData returns a DeploymentData struct initialized from a file and flags.
+// The file is rendered as a template first, SDLFile is the rendered sdl
 func NewDeploymentData(file string, flags *pflag.FlagSet, depAddr sdk.AccAddress) (*DeploymentData, error) {
-	f, err := ioutil.ReadFile(file)
+	f, err := ReadSDLFile(file, flags)
 	if err != nil {
 		return nil, err
 	}
+	dd, err := DeploymentDataFromSDL(f)
+	if err != nil {
+		return nil, err
+	}
+	if dd.DeploymentID, err = dcli.DeploymentIDFromFlags(flags, depAddr.String()); err != nil {
+		return nil, err
+	}
+	if dd.DeploymentID.DSeq == 0 {
+		if dd.DeploymentID.DSeq, err = config.BlockHeight(); err != nil {
+			return nil, err
+		}
+	}
+	return dd, nil
+}
+
+// DeploymentDataFromSDL returns a DeploymentData struct with the groups, manifest and version of the sdl, without an ID
+func DeploymentDataFromSDL(f []byte) (*DeploymentData, error) {
 	sdlSpec, err := sdl.Read(f)
 	if err != nil {
 		return nil, err
@@ -135,23 +228,18 @@ func NewDeploymentData(file string, flags *pflag.FlagSet, depAddr sdk.AccAddress
 	if err != nil {
 		return nil, err
 	}
-	id, err := dcli.DeploymentIDFromFlags(flags, depAddr.String())
+	providers, err := sdlProviderFilter(f)
 	if err != nil {
 		return nil, err
 	}
-	if id.DSeq == 0 {
-		if id.DSeq, err = config.BlockHeight(); err != nil {
-			return nil, err
-		}
-	}
 	return &DeploymentData{
-		SDLFile:      f,
-		SDL:          sdlSpec,
-		Manifest:     mani,
-		Groups:       groups,
-		DeploymentID: id,
-		OrderID:      make([]mtypes.OrderID, 0),
-		LeaseID:      make([]mtypes.LeaseID, 0),
-		Version:      ver,
+		SDLFile:   f,
+		SDL:       sdlSpec,
+		Manifest:  mani,
+		Groups:    groups,
+		OrderID:   make([]mtypes.OrderID, 0),
+		LeaseID:   make([]mtypes.LeaseID, 0),
+		Version:   ver,
+		Providers: providers,
 	}, nil
 }
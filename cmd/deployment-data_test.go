@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	dtypes "github.com/ovrclk/akash/x/deployment/types"
+	mtypes "github.com/ovrclk/akash/x/market/types"
+)
+
+func TestDeploymentDataBids(t *testing.T) {
+	config = &Config{}
+	owner := testAddress("owner")
+	dd := &DeploymentData{DeploymentID: dtypes.DeploymentID{Owner: owner, DSeq: 10}}
+	handle := DeploymentDataUpdateHandler(dd)
+
+	order1 := mtypes.OrderID{Owner: owner, DSeq: 10, GSeq: 1, OSeq: 1}
+	order2 := mtypes.OrderID{Owner: owner, DSeq: 10, GSeq: 2, OSeq: 1}
+	other := mtypes.OrderID{Owner: owner, DSeq: 11, GSeq: 1, OSeq: 1}
+	p1, p2 := testAddress("provider1"), testAddress("provider2")
+	price := func(amount int64) sdk.Coin { return sdk.NewInt64Coin("akash", amount) }
+
+	for _, ev := range []interface{}{
+		mtypes.EventOrderCreated{ID: order1},
+		mtypes.EventOrderCreated{ID: order2},
+		mtypes.EventBidCreated{ID: mtypes.MakeBidID(order1, p1), Price: price(20)},
+		mtypes.EventBidCreated{ID: mtypes.MakeBidID(order1, p2), Price: price(10)},
+		mtypes.EventBidCreated{ID: mtypes.MakeBidID(order2, p1), Price: price(30)},
+		mtypes.EventBidCreated{ID: mtypes.MakeBidID(other, p1), Price: price(40)},
+		mtypes.EventLeaseCreated{ID: mtypes.MakeLeaseID(mtypes.MakeBidID(order1, p2)), Price: price(10)},
+		mtypes.EventBidClosed{ID: mtypes.MakeBidID(order1, p1), Price: price(20)},
+	} {
+		if err := handle(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		order mtypes.OrderID
+		want  map[string]mtypes.BidState
+	}{
+		{order1, map[string]mtypes.BidState{p1.String(): mtypes.BidClosed, p2.String(): mtypes.BidMatched}},
+		{order2, map[string]mtypes.BidState{p1.String(): mtypes.BidOpen}},
+		{other, map[string]mtypes.BidState{}},
+	}
+	for _, tt := range tests {
+		bids := dd.OrderBids(tt.order)
+		if len(bids) != len(tt.want) {
+			t.Fatalf("expected %d bids for order %d, got %v", len(tt.want), tt.order.GSeq, bids)
+		}
+		for _, b := range bids {
+			if state, ok := tt.want[b.BidID.Provider.String()]; !ok || b.State != state {
+				t.Errorf("expected bid of %s on order %d to be %s, got %s", b.BidID.Provider, tt.order.GSeq, state, b.State)
+			}
+		}
+	}
+
+	if pending := dd.PendingOrders(); len(pending) != 1 || !pending[0].Equals(order2) {
+		t.Errorf("expected order 2 to be pending, got %v", pending)
+	}
+}
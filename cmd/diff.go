@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ovrclk/akash/manifest"
+	"github.com/ovrclk/akash/sdl"
+	dmodule "github.com/ovrclk/akash/x/deployment"
+	dtypes "github.com/ovrclk/akash/x/deployment/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagNoColor = "no-color"
+
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+func init() {
+	rootCmd.AddCommand(diffCmd())
+}
+
+// diffCmd represents the diff command
+func diffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [dseq] [sdl-file]",
+		Short: "show what updating a deployment with an sdl would change, service by service",
+		Long: `show what updating a deployment with an sdl would change, service by service
+
+the sdl is compared with the latest revision in the deployment archive. the
+manifest version of the sdl is also compared with the version of the deployment
+on chain, they differ when the deployment would be updated`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dseq, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid dseq %q: %w", args[0], err)
+			}
+			owner, err := ownerFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+
+			a, err := config.OpenArchivedDeployment(owner.String(), dseq)
+			if err != nil {
+				return err
+			}
+			_, old, err := a.Revision(a.Latest().Revision)
+			if err != nil {
+				return err
+			}
+			oldMani, err := sdlManifest(old)
+			if err != nil {
+				return fmt.Errorf("archived sdl: %w", err)
+			}
+			buf, err := ReadSDLFile(args[1], cmd.Flags())
+			if err != nil {
+				return err
+			}
+			newMani, err := sdlManifest(buf)
+			if err != nil {
+				return fmt.Errorf("%s: %w", args[1], err)
+			}
+
+			d, err := diffManifests(oldMani, newMani)
+			if err != nil {
+				return err
+			}
+			if config.ChainID != "" {
+				d.chainVersion(owner, dseq)
+			}
+
+			if config.Output == "json" {
+				bz, err := json.MarshalIndent(d, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(bz))
+				return nil
+			}
+			noColor, err := cmd.Flags().GetBool(flagNoColor)
+			if err != nil {
+				return err
+			}
+			d.Print(!noColor && isTerminal(os.Stdout))
+			return nil
+		},
+	}
+	cmd.Flags().String(flagOwner, "", "owner of the deployment, defaults to the configured key")
+	cmd.Flags().Bool(flagNoColor, false, "don't colour the output")
+	AddSDLTemplateFlags(cmd.Flags())
+	return cmd
+}
+
+// sdlManifest reads the sdl and returns its manifest
+func sdlManifest(buf []byte) (manifest.Manifest, error) {
+	s, err := sdl.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return s.Manifest()
+}
+
+// manifestChange is a difference in one field of a service
+type manifestChange struct {
+	Group   string `json:"group"`
+	Service string `json:"service"`
+	// Field is empty when the whole service is added or removed
+	Field string `json:"field,omitempty"`
+	// Change is added, removed or changed
+	Change string `json:"change"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+}
+
+// manifestDiff is the difference between two manifests and their versions
+type manifestDiff struct {
+	Changes []manifestChange `json:"changes"`
+
+	ArchivedVersion string `json:"archived-version"`
+	NewVersion      string `json:"new-version"`
+	// ChainVersion is empty when the deployment couldn't be queried
+	ChainVersion string `json:"chain-version,omitempty"`
+	ChainError   string `json:"chain-error,omitempty"`
+}
+
+// diffManifests compares the services of each group of the manifests
+func diffManifests(old, new manifest.Manifest) (*manifestDiff, error) {
+	d := &manifestDiff{Changes: []manifestChange{}}
+	for _, v := range []struct {
+		m   manifest.Manifest
+		out *string
+	}{{old, &d.ArchivedVersion}, {new, &d.NewVersion}} {
+		ver, err := sdl.ManifestVersion(v.m)
+		if err != nil {
+			return nil, err
+		}
+		*v.out = hex.EncodeToString(ver)
+	}
+
+	oldSvcs, newSvcs := manifestServices(old), manifestServices(new)
+	for _, key := range unionKeys(oldSvcs, newSvcs) {
+		group, name := splitServiceKey(key)
+		o, inOld := oldSvcs[key]
+		n, inNew := newSvcs[key]
+		switch {
+		case !inOld:
+			d.Changes = append(d.Changes, manifestChange{Group: group, Service: name, Change: "added", New: n.Image})
+		case !inNew:
+			d.Changes = append(d.Changes, manifestChange{Group: group, Service: name, Change: "removed", Old: o.Image})
+		default:
+			d.Changes = append(d.Changes, diffServices(group, o, n)...)
+		}
+	}
+	return d, nil
+}
+
+// diffServices compares the fields of a service
+func diffServices(group string, o, n manifest.Service) (changes []manifestChange) {
+	changed := func(field, old, new string) {
+		if old != new {
+			changes = append(changes, manifestChange{Group: group, Service: o.Name, Field: field, Change: "changed", Old: old, New: new})
+		}
+	}
+	listed := func(field string, old, new []string) {
+		oldSet, newSet := make(map[string]bool), make(map[string]bool)
+		for _, v := range old {
+			oldSet[v] = true
+		}
+		for _, v := range new {
+			newSet[v] = true
+		}
+		for _, v := range old {
+			if !newSet[v] {
+				changes = append(changes, manifestChange{Group: group, Service: o.Name, Field: field, Change: "removed", Old: v})
+			}
+		}
+		for _, v := range new {
+			if !oldSet[v] {
+				changes = append(changes, manifestChange{Group: group, Service: o.Name, Field: field, Change: "added", New: v})
+			}
+		}
+	}
+
+	changed("image", o.Image, n.Image)
+	changed("command", strings.Join(o.Command, " "), strings.Join(n.Command, " "))
+	changed("args", strings.Join(o.Args, " "), strings.Join(n.Args, " "))
+	listed("env", o.Env, n.Env)
+	changed("cpu", fmt.Sprintf("%dm", o.Unit.CPU), fmt.Sprintf("%dm", n.Unit.CPU))
+	changed("memory", formatBytes(o.Unit.Memory), formatBytes(n.Unit.Memory))
+	changed("storage", formatBytes(o.Unit.Storage), formatBytes(n.Unit.Storage))
+	changed("count", fmt.Sprint(o.Count), fmt.Sprint(n.Count))
+	listed("expose", exposeRules(o.Expose), exposeRules(n.Expose))
+	return changes
+}
+
+// chainVersion sets the version of the deployment on chain
+func (d *manifestDiff) chainVersion(owner sdk.AccAddress, dseq uint64) {
+	dclient := dmodule.AppModuleBasic{}.GetQueryClient(config.CLICtx(config.NewTMClient()))
+	res, err := dclient.Deployment(dtypes.DeploymentID{Owner: owner, DSeq: dseq})
+	if err != nil {
+		d.ChainError = err.Error()
+		return
+	}
+	d.ChainVersion = hex.EncodeToString(res.Version)
+}
+
+// Print prints the changes with -, + and ~ markers, coloured if color is set
+func (d *manifestDiff) Print(color bool) {
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+	if len(d.Changes) == 0 {
+		fmt.Println("no changes to services")
+	}
+	for _, c := range d.Changes {
+		svc := c.Group + "/" + c.Service
+		switch {
+		case c.Field == "" && c.Change == "added":
+			fmt.Println(paint(colorGreen, fmt.Sprintf("+ %s (%s)", svc, c.New)))
+		case c.Field == "" && c.Change == "removed":
+			fmt.Println(paint(colorRed, fmt.Sprintf("- %s (%s)", svc, c.Old)))
+		case c.Change == "added":
+			fmt.Println(paint(colorGreen, fmt.Sprintf("+ %s %s: %s", svc, c.Field, c.New)))
+		case c.Change == "removed":
+			fmt.Println(paint(colorRed, fmt.Sprintf("- %s %s: %s", svc, c.Field, c.Old)))
+		default:
+			fmt.Println(paint(colorYellow, fmt.Sprintf("~ %s %s: %s -> %s", svc, c.Field, c.Old, c.New)))
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("archived version: %s\n", d.ArchivedVersion)
+	fmt.Printf("new version:      %s\n", d.NewVersion)
+	switch {
+	case d.ChainError != "":
+		fmt.Printf("chain version:    unknown, %s\n", d.ChainError)
+	case d.ChainVersion == "":
+	case d.ChainVersion == d.NewVersion:
+		fmt.Printf("chain version:    %s, matches the sdl, an update would be a no-op\n", d.ChainVersion)
+	default:
+		fmt.Printf("chain version:    %s, an update would change the deployment\n", d.ChainVersion)
+	}
+}
+
+// manifestServices returns the services of the manifest keyed by group/service
+func manifestServices(m manifest.Manifest) map[string]manifest.Service {
+	out := make(map[string]manifest.Service)
+	for _, g := range m {
+		for _, s := range g.Services {
+			out[g.Name+"/"+s.Name] = s
+		}
+	}
+	return out
+}
+
+func splitServiceKey(key string) (group, service string) {
+	parts := strings.SplitN(key, "/", 2)
+	return parts[0], parts[1]
+}
+
+func unionKeys(a, b map[string]manifest.Service) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// exposeRules formats each expose rule, e.g. 80->8080/tcp global hosts=example.com
+func exposeRules(exposes []manifest.ServiceExpose) []string {
+	out := make([]string, 0, len(exposes))
+	for _, e := range exposes {
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "%d", e.Port)
+		if e.ExternalPort != 0 {
+			fmt.Fprintf(&b, "->%d", e.ExternalPort)
+		}
+		proto := strings.ToLower(e.Proto)
+		if proto == "" {
+			proto = "tcp"
+		}
+		fmt.Fprintf(&b, "/%s", proto)
+		if e.Global {
+			b.WriteString(" global")
+		}
+		if e.Service != "" {
+			fmt.Fprintf(&b, " to %s", e.Service)
+		}
+		if len(e.Hosts) > 0 {
+			fmt.Fprintf(&b, " hosts=%s", strings.Join(e.Hosts, ","))
+		}
+		out = append(out, b.String())
+	}
+	return out
+}
+
+// formatBytes formats a byte count with the largest binary suffix that divides it, e.g. 16Mi
+func formatBytes(n uint64) string {
+	suffixes := []string{"Ei", "Pi", "Ti", "Gi", "Mi", "Ki"}
+	for i, s := range suffixes {
+		unit := uint64(1) << (10 * uint(len(suffixes)-i))
+		if n >= unit && n%unit == 0 {
+			return fmt.Sprintf("%d%s", n/unit, s)
+		}
+	}
+	return fmt.Sprint(n)
+}
+
+// isTerminal returns true if the file is a terminal
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffManifests(t *testing.T) {
+	withDB := strings.Replace(testSDL, "profiles:", "  db:\n    image: postgres\n\nprofiles:", 1)
+	withDB = strings.Replace(withDB, "      count: 1\n", "      count: 1\n  db:\n    westcoast:\n      profile: web\n      count: 1\n", 1)
+
+	tests := []struct {
+		name     string
+		old, new string
+		want     []manifestChange
+	}{
+		{
+			name: "no changes",
+			old:  testSDL,
+			new:  testSDL,
+			want: []manifestChange{},
+		},
+		{
+			name: "image",
+			old:  testSDL,
+			new:  strings.Replace(testSDL, "demo-app", "demo-app:v2", 1),
+			want: []manifestChange{
+				{Group: "westcoast", Service: "web", Field: "image", Change: "changed", Old: "quay.io/ovrclk/demo-app", New: "quay.io/ovrclk/demo-app:v2"},
+			},
+		},
+		{
+			name: "resources and count",
+			old:  testSDL,
+			new:  strings.Replace(strings.Replace(testSDL, "16Mi", "32Mi", 1), "count: 1", "count: 2", 1),
+			want: []manifestChange{
+				{Group: "westcoast", Service: "web", Field: "memory", Change: "changed", Old: "16Mi", New: "32Mi"},
+				{Group: "westcoast", Service: "web", Field: "count", Change: "changed", Old: "1", New: "2"},
+			},
+		},
+		{
+			name: "env added",
+			old:  testSDL,
+			new:  strings.Replace(testSDL, "    expose:", "    env:\n      - A=1\n    expose:", 1),
+			want: []manifestChange{
+				{Group: "westcoast", Service: "web", Field: "env", Change: "added", New: "A=1"},
+			},
+		},
+		{
+			name: "expose changed",
+			old:  testSDL,
+			new:  strings.Replace(testSDL, "as: 80", "as: 8080", 1),
+			want: []manifestChange{
+				{Group: "westcoast", Service: "web", Field: "expose", Change: "removed", Old: "80->80/tcp global"},
+				{Group: "westcoast", Service: "web", Field: "expose", Change: "added", New: "80->8080/tcp global"},
+			},
+		},
+		{
+			name: "service added",
+			old:  testSDL,
+			new:  withDB,
+			want: []manifestChange{{Group: "westcoast", Service: "db", Change: "added", New: "postgres"}},
+		},
+		{
+			name: "service removed",
+			old:  withDB,
+			new:  testSDL,
+			want: []manifestChange{{Group: "westcoast", Service: "db", Change: "removed", Old: "postgres"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, err := sdlManifest([]byte(tt.old))
+			if err != nil {
+				t.Fatal(err)
+			}
+			new, err := sdlManifest([]byte(tt.new))
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := diffManifests(old, new)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(d.Changes) != len(tt.want) {
+				t.Fatalf("expected changes %+v, got %+v", tt.want, d.Changes)
+			}
+			for i := range tt.want {
+				if d.Changes[i] != tt.want[i] {
+					t.Errorf("expected change %+v, got %+v", tt.want[i], d.Changes[i])
+				}
+			}
+			if sameVersion := d.ArchivedVersion == d.NewVersion; sameVersion != (len(tt.want) == 0) {
+				t.Errorf("expected versions to match only without changes, got %s and %s", d.ArchivedVersion, d.NewVersion)
+			}
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[uint64]string{
+		0:          "0",
+		1000:       "1000",
+		1024:       "1Ki",
+		16 << 20:   "16Mi",
+		1536 << 20: "1536Mi",
+		2 << 30:    "2Gi",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %s, expected %s", n, got, want)
+		}
+	}
+}
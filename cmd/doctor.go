@@ -0,0 +1,254 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/auth"
+	"github.com/ovrclk/akash/provider/gateway"
+	dcli "github.com/ovrclk/akash/x/deployment/client/cli"
+	"github.com/ovrclk/akash/x/market"
+	mquery "github.com/ovrclk/akash/x/market/query"
+	mtypes "github.com/ovrclk/akash/x/market/types"
+	pmodule "github.com/ovrclk/akash/x/provider"
+	pquery "github.com/ovrclk/akash/x/provider/query"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// doctorPingTimeout bounds each provider gateway request made by doctor
+	doctorPingTimeout = 10 * time.Second
+)
+
+func init() {
+	rootCmd.AddCommand(doctorCmd())
+}
+
+// doctorCmd represents the doctor command
+func doctorCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor [sdl-file]",
+		Short: "diagnose why deployments fail: config, rpc, key, balance, sdl, providers and existing leases",
+		Long: `diagnose why deployments fail: config, rpc, key, balance, sdl, providers and existing leases
+
+each check prints [PASS] or [FAIL] with a hint on how to fix it. when an sdl-file is
+given it is validated and the account balance is checked against its pricing. the
+gateway of the provider of every active lease is asked for the lease status, pass
+--dseq to only check the leases of one deployment`,
+		Args: cobra.MaximumNArgs(1),
+		Annotations: map[string]string{
+			annotationLenient: "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d := &doctor{flags: cmd.Flags()}
+			if len(args) == 1 {
+				d.sdlFile = args[0]
+			}
+
+			failed := runChecks(os.Stdout, d.checks())
+			if d.ready() {
+				failed += runChecks(os.Stdout, d.leaseChecks())
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d checks failed", failed)
+			}
+			fmt.Println("no problems found")
+			return nil
+		},
+	}
+	dcli.AddDeploymentIDFlags(cmd.Flags())
+	AddSDLTemplateFlags(cmd.Flags())
+	return cmd
+}
+
+// doctor holds the state shared between the checks run by the doctor command
+type doctor struct {
+	flags   *pflag.FlagSet
+	sdlFile string
+
+	dd        *DeploymentData
+	providers pquery.Providers
+}
+
+// ready returns true if the chain can be queried
+func (d *doctor) ready() bool {
+	return config.Amino != nil && config.GetAccAddress() != nil
+}
+
+// checks returns the config, network, sdl and provider registry checks
+func (d *doctor) checks() []check {
+	checks := []check{
+		{
+			name: fmt.Sprintf("config file %s parses", cfgPath),
+			run: func() error {
+				file, err := ioutil.ReadFile(cfgPath)
+				if os.IsNotExist(err) && config.ChainID != "" {
+					// the network is set from the environment or flags
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				return yaml.UnmarshalStrict(file, &Config{})
+			},
+			hint: "create one with `deploy init`, or fix it with `deploy config edit`",
+		},
+		{
+			name: "network is selected",
+			run: func() error {
+				if config.ChainID == "" || config.RPCAddr == "" {
+					return fmt.Errorf("no %s or %s", flagChainID, flagRPCAddr)
+				}
+				return nil
+			},
+			hint: fmt.Sprintf("add a network with `deploy network add`, or pass --%s and --%s", flagChainID, flagRPCAddr),
+		},
+	}
+	if config.ChainID == "" {
+		return checks
+	}
+	checks = append(checks, config.setupChecks()...)
+
+	if d.sdlFile != "" {
+		checks = append(checks,
+			check{
+				name: fmt.Sprintf("sdl %s is valid", d.sdlFile),
+				run: func() (err error) {
+					d.dd, err = NewDeploymentData(d.sdlFile, d.flags, config.GetAccAddress())
+					return err
+				},
+				hint: "fix the sdl, `sample.yaml` in the deploy repo is a working example",
+			},
+			check{
+				name: "balance covers the sdl pricing",
+				run:  d.checkBalance,
+				hint: "fund `deploy address` from a faucet, or lower the sdl's pricing",
+			},
+		)
+	}
+
+	checks = append(checks, check{
+		name: "provider registry is reachable",
+		run: func() (err error) {
+			if !d.ready() {
+				return fmt.Errorf("no key loaded")
+			}
+			pclient := pmodule.AppModuleBasic{}.GetQueryClient(config.CLICtx(config.NewTMClient()))
+			if d.providers, err = pclient.Providers(); err != nil {
+				return err
+			}
+			if len(d.providers) == 0 {
+				return fmt.Errorf("no providers registered on %s", config.ChainID)
+			}
+			return nil
+		},
+		hint: "deployments only get leases once a provider is registered on the network",
+	})
+	if d.sdlFile != "" {
+		checks = append(checks, check{
+			name: "providers match the sdl placement",
+			run:  d.checkPlacement,
+			hint: "relax the sdl's placement attributes so a registered provider can bid",
+		})
+	}
+	return checks
+}
+
+// checkBalance compares the account balance with the sdl's maximum price per block
+func (d *doctor) checkBalance() error {
+	if d.dd == nil {
+		return fmt.Errorf("no valid sdl")
+	}
+	if !d.ready() {
+		return fmt.Errorf("no key loaded")
+	}
+	acc, err := auth.NewAccountRetriever(config.CLICtx(config.NewTMClient())).GetAccount(config.GetAccAddress())
+	if err != nil {
+		return err
+	}
+
+	var price sdk.Coins
+	for _, g := range d.dd.Groups {
+		price = price.Add(g.Price())
+	}
+	if !acc.GetCoins().IsAllGTE(price) {
+		return fmt.Errorf("balance %s is less than the maximum price of %s per block", acc.GetCoins(), price)
+	}
+	return nil
+}
+
+// checkPlacement ensures a registered provider has the attributes required by each group of the sdl
+func (d *doctor) checkPlacement() error {
+	if d.dd == nil || d.providers == nil {
+		return fmt.Errorf("no valid sdl or providers")
+	}
+groups:
+	for _, g := range d.dd.Groups {
+		for _, p := range d.providers {
+			if g.MatchAttributes(p.Attributes) {
+				continue groups
+			}
+		}
+		return fmt.Errorf("no provider has the attributes %v required by group %s", g.Requirements, g.Name)
+	}
+	return nil
+}
+
+// leaseChecks returns a check asking the gateway of each active lease's provider for the lease status
+func (d *doctor) leaseChecks() []check {
+	id, err := dcli.DeploymentIDFromFlags(d.flags, config.GetAccAddress().String())
+	if err != nil {
+		return []check{{name: "deployment id flags", run: func() error { return err }}}
+	}
+
+	ctx := config.CLICtx(config.NewTMClient())
+	leases, err := market.AppModuleBasic{}.GetQueryClient(ctx).Leases(mquery.LeaseFilters{
+		Owner: id.Owner,
+		State: mtypes.LeaseActive,
+	})
+	if err != nil {
+		return []check{{
+			name: "active leases can be listed",
+			run:  func() error { return err },
+			hint: "check the rpc node serves queries",
+		}}
+	}
+
+	var checks []check
+	pclient := pmodule.AppModuleBasic{}.GetQueryClient(ctx)
+	for _, l := range leases {
+		lid := l.LeaseID
+		if id.DSeq != 0 && lid.DSeq != id.DSeq {
+			continue
+		}
+		checks = append(checks, check{
+			name: fmt.Sprintf("provider %s serves lease %d/%d/%d", lid.Provider, lid.DSeq, lid.GSeq, lid.OSeq),
+			run: func() error {
+				p, err := pclient.Provider(lid.Provider)
+				if err != nil {
+					return err
+				}
+				ctx, cancel := context.WithTimeout(context.Background(), doctorPingTimeout)
+				defer cancel()
+				ls, err := gateway.NewClient().LeaseStatus(ctx, p.HostURI, lid)
+				if err != nil {
+					return fmt.Errorf("%s: %w", p.HostURI, err)
+				}
+				for _, s := range ls.Services {
+					if s.Available < s.Total {
+						return fmt.Errorf("service %s has %d of %d replicas available", s.Name, s.Available, s.Total)
+					}
+				}
+				return nil
+			},
+			hint: "the provider may be down or may not have the manifest, try re-creating the deployment",
+		})
+	}
+	return checks
+}
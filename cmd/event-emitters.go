@@ -4,7 +4,6 @@ import (
 	"context"
 	"path"
 
-	"github.com/ovrclk/akash/events"
 	"github.com/ovrclk/akash/pubsub"
 	"github.com/ovrclk/deploy/pathevents"
 	"golang.org/x/sync/errgroup"
@@ -24,12 +23,6 @@ func ChainAndFSEmitter(paths []string) func(context.Context, ...EventHandler) er
 		}
 		defer watcher.Close()
 
-		// Instantiate and start tendermint RPC client
-		client := config.NewTMClient()
-		if err = client.Start(); err != nil {
-			return err
-		}
-
 		// Start the pubsub bus
 		bus := pubsub.NewBus()
 		defer bus.Close()
@@ -39,7 +32,7 @@ func ChainAndFSEmitter(paths []string) func(context.Context, ...EventHandler) er
 
 		// Publish chain events to the pubsub bus
 		group.Go(func() error {
-			return events.Publish(ctx, client, "akash-deploy", bus)
+			return config.PublishChainEvents(ctx, "akash-deploy", bus)
 		})
 
 		// Publish filesystem events to the bus
@@ -80,12 +73,6 @@ func ChainAndFSEmitter(paths []string) func(context.Context, ...EventHandler) er
 
 // ChainEmitter runs the passed EventHandlers just on the on chain event stream
 func ChainEmitter(ctx context.Context, ehs ...EventHandler) (err error) {
-	// Instantiate and start tendermint RPC client
-	client := config.NewTMClient()
-	if err = client.Start(); err != nil {
-		return err
-	}
-
 	// Start the pubsub bus
 	bus := pubsub.NewBus()
 	defer bus.Close()
@@ -95,7 +82,7 @@ func ChainEmitter(ctx context.Context, ehs ...EventHandler) (err error) {
 
 	// Publish chain events to the pubsub bus
 	group.Go(func() error {
-		return events.Publish(ctx, client, "akash-deploy", bus)
+		return config.PublishChainEvents(ctx, "akash-deploy", bus)
 	})
 
 	// Subscribe to the bus events
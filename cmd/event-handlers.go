@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path"
 
@@ -21,34 +22,45 @@ type EventHandler func(pubsub.Event) error
 func SendManifestHander(dd *DeploymentData) func(pubsub.Event) error {
 	return func(ev pubsub.Event) (err error) {
 		addr := config.GetAccAddress()
-		log := logger.With("action", "send-manifest")
 		switch event := ev.(type) {
 		// Handle Lease creation events
 		case mtypes.EventLeaseCreated:
-			if addr.Equals(event.ID.Owner) {
-				pclient := pmodule.AppModuleBasic{}.GetQueryClient(config.CLICtx(config.NewTMClient()))
-				provider, err := pclient.Provider(event.ID.Provider)
-				if err != nil {
-					return err
-				}
-
-				log.Info("sending manifest to provider", "provider", event.ID.Provider, "uri", provider.HostURI, "dseq", event.ID.DSeq)
-				if err = gateway.NewClient().SubmitManifest(
-					context.Background(),
-					provider.HostURI,
-					&manifest.SubmitRequest{
-						Deployment: event.ID.DeploymentID(),
-						Manifest:   dd.Manifest,
-					},
-				); err != nil {
-					return err
+			if addr.Equals(event.ID.Owner) && event.ID.DSeq == dd.DeploymentID.DSeq {
+				// leases with providers that aren't allowed are handled by DeploymentDataUpdateHandler
+				if err := dd.CheckProvider(event.ID.Provider); errors.Is(err, errProviderNotAllowed) {
+					logger.Info("not sending manifest", "provider", event.ID.Provider, "dseq", event.ID.DSeq, "err", err)
+					return nil
 				}
+				return config.SendManifest(dd, event.ID)
 			}
 		}
 		return
 	}
 }
 
+// SendManifest sends the manifest of the DeploymentData to the provider of the lease
+func (c *Config) SendManifest(dd *DeploymentData, lid mtypes.LeaseID) error {
+	if err := dd.CheckProvider(lid.Provider); err != nil {
+		return err
+	}
+	log := logger.With("action", "send-manifest")
+	pclient := pmodule.AppModuleBasic{}.GetQueryClient(c.CLICtx(c.NewTMClient()))
+	provider, err := pclient.Provider(lid.Provider)
+	if err != nil {
+		return err
+	}
+
+	log.Info("sending manifest to provider", "provider", lid.Provider, "uri", provider.HostURI, "dseq", lid.DSeq)
+	return gateway.NewClient().SubmitManifest(
+		context.Background(),
+		provider.HostURI,
+		&manifest.SubmitRequest{
+			Deployment: lid.DeploymentID(),
+			Manifest:   dd.Manifest,
+		},
+	)
+}
+
 // DeploymentDataUpdateHandler updates a DeploymentData and prints relevant events
 func DeploymentDataUpdateHandler(dd *DeploymentData) func(pubsub.Event) error {
 	return func(ev pubsub.Event) (err error) {
@@ -74,6 +86,9 @@ func DeploymentDataUpdateHandler(dd *DeploymentData) func(pubsub.Event) error {
 			if event.ID.Equals(dd.DeploymentID) {
 				// TODO: Maybe we should exit here as the tracked deployment is now closed?
 				log.Info("deployment closed")
+				if err := dd.Archive.Close(); err != nil {
+					log.Error("error updating archive", "err", err)
+				}
 			}
 			return
 
@@ -104,22 +119,47 @@ func DeploymentDataUpdateHandler(dd *DeploymentData) func(pubsub.Event) error {
 		// Handle Bid creation events
 		case mtypes.EventBidCreated:
 			if addr.Equals(event.ID.Owner) && event.ID.DSeq == dd.DeploymentID.DSeq {
-				log.Info("bid for order created", "oseq", event.ID.OSeq, "price", event.Price)
+				dd.AddBid(event.ID, event.Price)
+				log.Info("bid for order created", "oseq", event.ID.OSeq, "provider", event.ID.Provider, "price", event.Price)
+				if err := dd.Archive.AddBid(event.ID, event.Price); err != nil {
+					log.Error("error updating archive", "err", err)
+				}
 			}
 			return
 
 		// Handle Bid close events
 		case mtypes.EventBidClosed:
 			if addr.Equals(event.ID.Owner) && event.ID.DSeq == dd.DeploymentID.DSeq {
-				log.Info("bid for order closed", "oseq", event.ID.OSeq, "price", event.Price)
+				dd.SetBidState(event.ID, mtypes.BidClosed)
+				log.Info("bid for order closed", "oseq", event.ID.OSeq, "provider", event.ID.Provider, "price", event.Price)
+				if err := dd.Archive.SetBidState(event.ID, mtypes.BidClosed); err != nil {
+					log.Error("error updating archive", "err", err)
+				}
 			}
 			return
 
 		// Handle Lease creation events
 		case mtypes.EventLeaseCreated:
 			if addr.Equals(event.ID.Owner) && event.ID.DSeq == dd.DeploymentID.DSeq {
+				if err := dd.CheckProvider(event.ID.Provider); err != nil {
+					if !errors.Is(err, errProviderNotAllowed) {
+						return err
+					}
+					log.Error("lease created with a provider that isn't allowed", "oseq", event.ID.OSeq, "provider", event.ID.Provider, "err", err)
+					if aerr := dd.Archive.AddLease(event.ID, event.Price); aerr != nil {
+						log.Error("error updating archive", "err", aerr)
+					}
+					return dd.RejectLease(event.ID, err)
+				}
 				dd.AddLease(event.ID)
+				dd.SetBidState(mtypes.BidID(event.ID), mtypes.BidMatched)
 				log.Info("lease for order created", "oseq", event.ID.OSeq, "price", event.Price)
+				if err := dd.Archive.AddLease(event.ID, event.Price); err != nil {
+					log.Error("error updating archive", "err", err)
+				}
+				if err := dd.Archive.SetBidState(mtypes.BidID(event.ID), mtypes.BidMatched); err != nil {
+					log.Error("error updating archive", "err", err)
+				}
 			}
 			return
 
@@ -128,6 +168,9 @@ func DeploymentDataUpdateHandler(dd *DeploymentData) func(pubsub.Event) error {
 			if addr.Equals(event.ID.Owner) && event.ID.DSeq == dd.DeploymentID.DSeq {
 				dd.RemoveLease(event.ID)
 				log.Info("lease for order closed", "oseq", event.ID.OSeq, "price", event.Price)
+				if err := dd.Archive.CloseLease(event.ID); err != nil {
+					log.Error("error updating archive", "err", err)
+				}
 			}
 			return
 
@@ -161,6 +204,7 @@ func PrintHandler(ev pubsub.Event) (err error) {
 	case dtypes.EventDeploymentClosed:
 		if addr.Equals(event.ID.Owner) {
 			log.Info("deployment closed", "dseq", event.ID.DSeq)
+			archiveEvent(event.ID.Owner.String(), event.ID.DSeq, (*ArchivedDeployment).Close)
 		}
 		return
 
@@ -210,6 +254,9 @@ func PrintHandler(ev pubsub.Event) (err error) {
 	case mtypes.EventLeaseClosed:
 		if addr.Equals(event.ID.Owner) {
 			log.Info("lease for order closed", "dseq", event.ID.DSeq, "oseq", event.ID.OSeq, "price", event.Price)
+			archiveEvent(event.ID.Owner.String(), event.ID.DSeq, func(a *ArchivedDeployment) error {
+				return a.CloseLease(event.ID)
+			})
 		}
 		return
 
@@ -228,26 +275,37 @@ func PrintHandler(ev pubsub.Event) (err error) {
 	}
 }
 
+// archiveEvent applies fn to the archive of the deployment, if it is archived
+func archiveEvent(owner string, dseq uint64, fn func(*ArchivedDeployment) error) {
+	a, err := config.OpenArchivedDeployment(owner, dseq)
+	if err != nil {
+		return
+	}
+	if err = fn(a); err != nil {
+		logger.Error("error updating archive", "dseq", dseq, "err", err)
+	}
+}
+
 // printFSEvents prints all filesystem events in the deployment directory
 func printFSEvents(event fsnotify.Event) error {
 	log := logger.With("events", "filesystem")
 	switch {
-	case path.Dir(event.Name) == path.Join(homePath, "deployments") && path.Ext(event.Name) == ".yaml":
+	case path.Dir(event.Name) == archivePath():
 		// TODO: New file created? we want to create a new deployment
 		// TODO: File modified? we want to update an existing deployment
 		// TODO: File moved? error and exit?
 		// TODO: File deleted? close deployement, error and exit?
 		switch event.Op {
 		case fsnotify.Create:
-			log.Info("deployment file", "file", path.Base(event.Name), "event", event.Op)
+			log.Info("archived deployment", "dir", path.Base(event.Name), "event", event.Op)
 		case fsnotify.Write:
-			log.Info("deployment file", "file", path.Base(event.Name), "event", event.Op)
+			log.Info("archived deployment", "dir", path.Base(event.Name), "event", event.Op)
 		case fsnotify.Remove:
-			log.Info("deployment file", "file", path.Base(event.Name), "event", event.Op)
+			log.Info("archived deployment", "dir", path.Base(event.Name), "event", event.Op)
 		case fsnotify.Rename:
-			log.Info("deployment file", "file", path.Base(event.Name), "event", event.Op)
+			log.Info("archived deployment", "dir", path.Base(event.Name), "event", event.Op)
 		case fsnotify.Chmod:
-			log.Info("deployment file", "file", path.Base(event.Name), "event", event.Op)
+			log.Info("archived deployment", "dir", path.Base(event.Name), "event", event.Op)
 		}
 		return nil
 	case path.Dir(event.Name) == defaultHome:
@@ -0,0 +1,271 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ovrclk/akash/provider/cluster"
+	"github.com/ovrclk/akash/provider/gateway"
+	dtypes "github.com/ovrclk/akash/x/deployment/types"
+	mtypes "github.com/ovrclk/akash/x/market/types"
+	pmodule "github.com/ovrclk/akash/x/provider"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// eventsStatusTimeout is how long to wait for a provider to report the status of a lease
+	eventsStatusTimeout = 10 * time.Second
+	// eventsLogTimeout is how long to wait for the recent logs of a service
+	eventsLogTimeout = 10 * time.Second
+	// eventsTimeout is how long printDeploymentEvents waits for the events of every lease
+	eventsTimeout = time.Minute
+)
+
+func init() {
+	rootCmd.AddCommand(eventsCmd())
+}
+
+// eventsCmd represents the events command
+func eventsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "events [dseq]",
+		Short: "show why the services of a deployment aren't available, from the status and logs reported by providers",
+		Long: `show why the services of a deployment aren't available, from the status and logs reported by providers
+
+the replicas of each service are shown as counted by the provider: created, updated
+to the latest manifest, ready and available. the last --tail lines of the logs of
+services that aren't available are shown with them.
+
+provider gateways don't expose kubernetes events, so image pull errors, crash loops
+or pending scheduling aren't reported as such. the diagnosis lines are inferred from
+the replica counts and logs, e.g. replicas created but not ready with no logs point
+to an image that fails to pull or replicas that can't be scheduled.
+this is printed automatically when a deployment isn't available in time`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dseq, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid dseq %q: %w", args[0], err)
+			}
+			owner, err := ownerFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			tail, err := cmd.Flags().GetInt64(flagTail)
+			if err != nil {
+				return err
+			}
+			services, err := cmd.Flags().GetStringSlice(flagService)
+			if err != nil {
+				return err
+			}
+
+			leases, err := config.ActiveLeases(dtypes.DeploymentID{Owner: owner, DSeq: dseq})
+			if err != nil {
+				return err
+			}
+			if len(leases) == 0 {
+				return fmt.Errorf("deployment %s/%d has no active leases", owner, dseq)
+			}
+			events, err := deploymentEvents(context.Background(), gateway.NewClient(), leases, tail)
+			if err != nil {
+				return err
+			}
+			if len(services) > 0 {
+				var filtered []*serviceEvents
+				for _, e := range events {
+					if contains(services, e.Service) {
+						filtered = append(filtered, e)
+					}
+				}
+				events = filtered
+			}
+
+			if config.Output == "json" {
+				if events == nil {
+					events = []*serviceEvents{}
+				}
+				return printJSON(events)
+			}
+			printServiceEvents(events)
+			return nil
+		},
+	}
+	cmd.Flags().String(flagOwner, "", "owner of the deployment, defaults to the configured key")
+	cmd.Flags().Int64(flagTail, 20, "number of log lines to show for each replica of services that aren't available, 0 for none")
+	cmd.Flags().StringSlice(flagService, nil, "only show this service, can be repeated")
+	return cmd
+}
+
+// serviceEvents is the status of a service as reported by its provider, with what it is inferred to point to
+type serviceEvents struct {
+	Provider          string   `json:"provider"`
+	GSeq              uint32   `json:"gseq"`
+	OSeq              uint32   `json:"oseq"`
+	Service           string   `json:"service"`
+	Available         int32    `json:"available"`
+	Total             int32    `json:"total"`
+	Replicas          int32    `json:"replicas"`
+	UpdatedReplicas   int32    `json:"updated-replicas"`
+	ReadyReplicas     int32    `json:"ready-replicas"`
+	AvailableReplicas int32    `json:"available-replicas"`
+	URIs              []string `json:"uris,omitempty"`
+	Diagnosis         []string `json:"diagnosis"`
+	Logs              []string `json:"logs,omitempty"`
+	LogError          string   `json:"log-error,omitempty"`
+}
+
+// deploymentEvents queries the status of the services of each lease from their providers, with
+// the last tail log lines of the services that aren't available
+func deploymentEvents(ctx context.Context, client gateway.Client, leases []mtypes.LeaseID, tail int64) ([]*serviceEvents, error) {
+	pclient := pmodule.AppModuleBasic{}.GetQueryClient(config.CLICtx(config.NewTMClient()))
+	var out []*serviceEvents
+	for _, lid := range leases {
+		p, err := pclient.Provider(lid.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("looking up provider %s: %w", lid.Provider, err)
+		}
+		sctx, cancel := context.WithTimeout(ctx, eventsStatusTimeout)
+		ls, err := client.LeaseStatus(sctx, p.HostURI, lid)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("querying lease status from %s: %w", lid.Provider, err)
+		}
+		for _, s := range ls.Services {
+			e := newServiceEvents(lid, s)
+			if s.Available < s.Total && tail != 0 {
+				e.Logs, err = recentLogs(ctx, client, p.HostURI, lid, s.Name, tail)
+				if err != nil {
+					e.LogError = err.Error()
+				}
+			}
+			e.diagnose(tail != 0)
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func newServiceEvents(lid mtypes.LeaseID, s *cluster.ServiceStatus) *serviceEvents {
+	return &serviceEvents{
+		Provider:          lid.Provider.String(),
+		GSeq:              lid.GSeq,
+		OSeq:              lid.OSeq,
+		Service:           s.Name,
+		Available:         s.Available,
+		Total:             s.Total,
+		Replicas:          s.Replicas,
+		UpdatedReplicas:   s.UpdatedReplicas,
+		ReadyReplicas:     s.ReadyReplicas,
+		AvailableReplicas: s.AvailableReplicas,
+		URIs:              s.URIs,
+		Diagnosis:         []string{},
+	}
+}
+
+// recentLogs returns the last tail lines of the logs of the replicas of a service, prefixed with the replica
+func recentLogs(ctx context.Context, client gateway.Client, host string, lid mtypes.LeaseID, service string, tail int64) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, eventsLogTimeout)
+	defer cancel()
+	logs, err := client.ServiceLogs(ctx, host, lid, service, false, tail)
+	if err != nil {
+		return nil, err
+	}
+	var lines []string
+	for {
+		select {
+		case msg, ok := <-logs.Stream:
+			if !ok {
+				return lines, nil
+			}
+			lines = append(lines, msg.Name+" | "+msg.Message)
+		case <-ctx.Done():
+			return lines, fmt.Errorf("timed out after %s waiting for logs", eventsLogTimeout)
+		}
+	}
+}
+
+// diagnose infers what the replica counts of the service, and its logs if they were fetched,
+// point to. These are guesses, gateways don't expose the kubernetes events of the replicas
+func (e *serviceEvents) diagnose(fetchedLogs bool) {
+	add := func(format string, args ...interface{}) {
+		e.Diagnosis = append(e.Diagnosis, fmt.Sprintf(format, args...))
+	}
+	if e.Available >= e.Total {
+		add("available")
+		return
+	}
+	switch {
+	case e.Replicas == 0:
+		add("no replicas created: the provider may still be deploying the manifest, or couldn't")
+	case e.UpdatedReplicas < e.Replicas:
+		add("rolling out: %d of %d replicas run the latest manifest", e.UpdatedReplicas, e.Replicas)
+	}
+	if e.Replicas > 0 && e.ReadyReplicas < e.Replicas {
+		add("%d of %d replicas not ready: pending scheduling, pulling the image, or crashing", e.Replicas-e.ReadyReplicas, e.Replicas)
+	}
+	if e.ReadyReplicas > e.AvailableReplicas {
+		add("%d ready replicas not yet available", e.ReadyReplicas-e.AvailableReplicas)
+	}
+	if !fetchedLogs {
+		return
+	}
+	if e.Replicas > 0 && len(e.Logs) == 0 && e.LogError == "" {
+		add("no logs: no container has started, the image may fail to pull or the replicas can't be scheduled")
+	} else if len(e.Logs) > 0 && e.ReadyReplicas < e.Replicas {
+		add("containers have started but aren't ready, they may be crash looping, see the logs")
+	}
+}
+
+func printServiceEvents(events []*serviceEvents) {
+	for i, e := range events {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("service %s, lease %d/%d with %s\n", e.Service, e.GSeq, e.OSeq, e.Provider)
+		fmt.Printf("  available %d/%d, replicas %d created, %d updated, %d ready, %d available\n",
+			e.Available, e.Total, e.Replicas, e.UpdatedReplicas, e.ReadyReplicas, e.AvailableReplicas)
+		if len(e.URIs) > 0 {
+			fmt.Printf("  uris: %s\n", strings.Join(e.URIs, ", "))
+		}
+		if len(e.Diagnosis) > 0 {
+			fmt.Printf("  inferred from the replica counts and logs:\n")
+		}
+		for _, d := range e.Diagnosis {
+			fmt.Printf("  - %s\n", d)
+		}
+		if e.LogError != "" {
+			fmt.Printf("  couldn't get logs: %s\n", e.LogError)
+		}
+		if len(e.Logs) > 0 {
+			fmt.Printf("  last log lines:\n")
+			for _, l := range e.Logs {
+				fmt.Printf("    %s\n", l)
+			}
+		}
+	}
+}
+
+// printDeploymentEvents prints the events of the leases to explain why a deployment isn't available
+func printDeploymentEvents(leases []mtypes.LeaseID) {
+	if len(leases) == 0 {
+		logger.Info("no leases were created, see `deploy bids` for the bids on the orders")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), eventsTimeout)
+	defer cancel()
+	events, err := deploymentEvents(ctx, gateway.NewClient(), leases, 20)
+	if err != nil {
+		logger.Error("couldn't query the services from the providers", "err", err)
+		return
+	}
+	if config.Output == "json" {
+		_ = printJSON(events)
+		return
+	}
+	fmt.Println()
+	printServiceEvents(events)
+}
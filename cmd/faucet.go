@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/bank"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagListen    = "listen"
+	flagAmount    = "amount"
+	flagRateLimit = "rate-limit"
+	flagAddress   = "address"
+)
+
+// maxFaucetRequest is the largest request body the faucet reads
+const maxFaucetRequest = 1 << 10
+
+func init() {
+	rootCmd.AddCommand(faucetCmd())
+}
+
+// faucetCmd represents the faucet command
+func faucetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "faucet",
+		Short: "run a local faucet that funds deployer keys, or request coins from one",
+	}
+	cmd.AddCommand(
+		faucetServeCmd(),
+		faucetRequestCmd(),
+	)
+	return cmd
+}
+
+func faucetServeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "serve a faucet over http, sending coins from the configured key",
+		Long: `serve a faucet over http, sending coins from the configured key
+
+the configured key must be funded, e.g. pass --keyfile or --network to use a
+genesis account of a local chain. each address can be funded once per --rate-limit
+
+  GET  /  returns the faucet's address, chain-id and amount
+  POST /  with {"address": "akash1..."} sends the amount to the address`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := config.SetGasOnConfig(); err != nil {
+				return err
+			}
+			if config.GetAccAddress() == nil {
+				return fmt.Errorf("no key loaded, the faucet needs a funded key")
+			}
+
+			listen, err := cmd.Flags().GetString(flagListen)
+			if err != nil {
+				return err
+			}
+			amt, err := cmd.Flags().GetString(flagAmount)
+			if err != nil {
+				return err
+			}
+			amount, err := sdk.ParseCoins(amt)
+			if err != nil {
+				return err
+			}
+			limit, err := cmd.Flags().GetDuration(flagRateLimit)
+			if err != nil {
+				return err
+			}
+
+			f := &faucet{
+				from:   config.GetAccAddress(),
+				send:   config.SendMsgs,
+				amount: amount,
+				limit:  limit,
+				funded: make(map[string]time.Time),
+			}
+			logger.Info("serving faucet", "listen", listen, "address", config.GetAccAddress(), "amount", amount)
+			return http.ListenAndServe(listen, f)
+		},
+	}
+	cmd.Flags().String(flagListen, "127.0.0.1:8081", "address to listen on, e.g. :8081 to accept requests from other hosts")
+	cmd.Flags().String(flagAmount, "1000akash", "coins to send to each address")
+	cmd.Flags().Duration(flagRateLimit, time.Hour, "how long an address has to wait before it can be funded again")
+	return cmd
+}
+
+func faucetRequestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "request [url]",
+		Short: "request coins for the configured key from a faucet started with `deploy faucet serve`",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			addr, err := cmd.Flags().GetString(flagAddress)
+			if err != nil {
+				return err
+			}
+			if addr == "" {
+				if config.GetAccAddress() == nil {
+					return fmt.Errorf("no key loaded, create one with `deploy key-add` or pass --%s", flagAddress)
+				}
+				addr = config.GetAccAddress().String()
+			}
+
+			bz, err := json.Marshal(faucetRequest{Address: addr})
+			if err != nil {
+				return err
+			}
+			resp, err := http.Post(strings.TrimRight(args[0], "/")+"/", "application/json", bytes.NewReader(bz))
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+
+			var res faucetResponse
+			if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+				return fmt.Errorf("faucet returned %s: %w", resp.Status, err)
+			}
+			if res.Error != "" {
+				return fmt.Errorf("faucet returned %s: %s", resp.Status, res.Error)
+			}
+			logger.Info("funded by faucet", "address", res.Address, "amount", res.Amount, "hash", res.TxHash)
+			return nil
+		},
+	}
+	cmd.Flags().String(flagAddress, "", "address to fund instead of the configured key's")
+	return cmd
+}
+
+// faucetRequest is the body of a request for coins
+type faucetRequest struct {
+	Address string `json:"address"`
+}
+
+// faucetResponse is the body of every faucet response
+type faucetResponse struct {
+	Address string `json:"address,omitempty"`
+	ChainID string `json:"chain-id,omitempty"`
+	Amount  string `json:"amount,omitempty"`
+	TxHash  string `json:"tx-hash,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// faucet is an http handler that sends coins from the configured key, funding each address at most once per limit
+type faucet struct {
+	// from is the address of the configured key, send signs and sends messages with it
+	from   sdk.AccAddress
+	send   func([]sdk.Msg) (sdk.TxResponse, error)
+	amount sdk.Coins
+	limit  time.Duration
+
+	mu     sync.Mutex
+	funded map[string]time.Time
+}
+
+func (f *faucet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		f.respond(w, http.StatusOK, faucetResponse{
+			Address: f.from.String(),
+			ChainID: config.ChainID,
+			Amount:  f.amount.String(),
+		})
+	case http.MethodPost:
+		f.fund(w, r)
+	default:
+		f.respond(w, http.StatusMethodNotAllowed, faucetResponse{Error: "only GET and POST are supported"})
+	}
+}
+
+// fund sends the faucet amount to the requested address
+func (f *faucet) fund(w http.ResponseWriter, r *http.Request) {
+	var req faucetRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxFaucetRequest)).Decode(&req); err != nil {
+		f.respond(w, http.StatusBadRequest, faucetResponse{Error: err.Error()})
+		return
+	}
+	to, err := sdk.AccAddressFromBech32(req.Address)
+	if err != nil {
+		f.respond(w, http.StatusBadRequest, faucetResponse{Error: err.Error()})
+		return
+	}
+	log := logger.With("action", "faucet", "to", to)
+
+	// reserve the address before sending so concurrent requests can't fund it twice
+	if wait := f.reserve(to.String()); wait > 0 {
+		w.Header().Set("Retry-After", fmt.Sprintf("%.0f", wait.Seconds()))
+		f.respond(w, http.StatusTooManyRequests, faucetResponse{
+			Address: to.String(),
+			Error:   fmt.Sprintf("address was funded recently, try again in %s", wait.Round(time.Second)),
+		})
+		return
+	}
+
+	res, err := f.send([]sdk.Msg{bank.NewMsgSend(f.from, to, f.amount)})
+	if err != nil {
+		f.release(to.String())
+		log.Error("tx failed", "hash", res.TxHash, "err", err)
+		f.respond(w, http.StatusInternalServerError, faucetResponse{Address: to.String(), TxHash: res.TxHash, Error: err.Error()})
+		return
+	}
+	log.Info("funded address", "amount", f.amount, "hash", res.TxHash)
+	f.respond(w, http.StatusOK, faucetResponse{Address: to.String(), Amount: f.amount.String(), TxHash: res.TxHash})
+}
+
+// reserve records the address as funded now, returning how long to wait if it was funded within the limit
+func (f *faucet) reserve(addr string) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if last, ok := f.funded[addr]; ok {
+		if wait := f.limit - time.Since(last); wait > 0 {
+			return wait
+		}
+	}
+	f.funded[addr] = time.Now()
+	return 0
+}
+
+// release forgets the address after a failed send so it can request again
+func (f *faucet) release(addr string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.funded, addr)
+}
+
+func (f *faucet) respond(w http.ResponseWriter, code int, res faucetResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logger.Error("writing faucet response", "err", err)
+	}
+}
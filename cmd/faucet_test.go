@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/bank"
+)
+
+func TestFaucetRateLimit(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		sent []string
+		// fail makes the sends to these addresses fail
+		fail = make(map[string]bool)
+	)
+	f := &faucet{
+		from: testAddress("faucet"),
+		send: func(msgs []sdk.Msg) (sdk.TxResponse, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			to := msgs[0].(bank.MsgSend).ToAddress.String()
+			sent = append(sent, to)
+			if fail[to] {
+				return sdk.TxResponse{}, errors.New("insufficient funds")
+			}
+			return sdk.TxResponse{TxHash: "ABC"}, nil
+		},
+		amount: sdk.NewCoins(sdk.NewInt64Coin("akash", 1000)),
+		limit:  time.Hour,
+		funded: make(map[string]time.Time),
+	}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	alice, bob := testAddress("alice").String(), testAddress("bob").String()
+	tests := []struct {
+		name   string
+		before func()
+		body   string
+		code   int
+		err    string
+		sends  int
+	}{
+		{"funded", nil, `{"address":"` + alice + `"}`, http.StatusOK, "", 1},
+		{"funded recently", nil, `{"address":"` + alice + `"}`, http.StatusTooManyRequests, "funded recently", 0},
+		{"failed send", func() { fail[bob] = true }, `{"address":"` + bob + `"}`, http.StatusInternalServerError, "insufficient funds", 1},
+		{"released after failure", func() { delete(fail, bob) }, `{"address":"` + bob + `"}`, http.StatusOK, "", 1},
+		{"funded again after the limit", func() {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			f.funded[alice] = time.Now().Add(-2 * time.Hour)
+		}, `{"address":"` + alice + `"}`, http.StatusOK, "", 1},
+		{"invalid address", nil, `{"address":"akash1bad"}`, http.StatusBadRequest, "decoding bech32", 0},
+		{"body too large", nil, `{"address":"` + strings.Repeat("a", 2*maxFaucetRequest) + `"}`, http.StatusBadRequest, "too large", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.before != nil {
+				mu.Lock()
+				tt.before()
+				mu.Unlock()
+			}
+			mu.Lock()
+			before := len(sent)
+			mu.Unlock()
+
+			resp, err := http.Post(srv.URL, "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var res faucetResponse
+			if err = json.NewDecoder(resp.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tt.code || !strings.Contains(res.Error, tt.err) {
+				t.Errorf("expected status %d and error %q, got %d: %s", tt.code, tt.err, resp.StatusCode, res.Error)
+			}
+			if tt.code == http.StatusTooManyRequests && resp.Header.Get("Retry-After") == "" {
+				t.Error("expected a Retry-After header")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if n := len(sent) - before; n != tt.sends {
+				t.Errorf("expected %d sends, got %d", tt.sends, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var flagOwner = "owner"
+
+// errNoOwner is wrapped by the error of ownerFromFlags when there is neither an --owner nor a key
+var errNoOwner = errors.New("no key loaded")
+
+func init() {
+	rootCmd.AddCommand(historyCmd())
+}
+
+// historyCmd represents the history command
+func historyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history [dseq]",
+		Short: "list archived deployments, or show the revisions, txs and leases of one",
+		Args:  cobra.MaximumNArgs(1),
+		Annotations: map[string]string{
+			annotationLenient: "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// without an owner or a key every owner's deployments are listed
+			owner, err := ownerFromFlags(cmd.Flags())
+			if err != nil && (len(args) > 0 || !errors.Is(err, errNoOwner)) {
+				return err
+			}
+
+			if len(args) == 0 {
+				list, err := config.ArchivedDeployments(owner.String())
+				if err != nil {
+					return err
+				}
+				if config.Output == "json" {
+					if list == nil {
+						list = []*ArchivedDeployment{}
+					}
+					return printJSON(list)
+				}
+				return printArchivedDeployments(list)
+			}
+
+			dseq, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid dseq %q: %w", args[0], err)
+			}
+			a, err := config.OpenArchivedDeployment(owner.String(), dseq)
+			if err != nil {
+				return err
+			}
+			if config.Output == "json" {
+				return printJSON(a)
+			}
+			return a.Print()
+		},
+	}
+	cmd.Flags().String(flagOwner, "", "owner of the deployments, defaults to the configured key")
+	return cmd
+}
+
+// ownerFromFlags returns the --owner address or the configured key's
+func ownerFromFlags(flags *pflag.FlagSet) (sdk.AccAddress, error) {
+	o, err := flags.GetString(flagOwner)
+	if err != nil {
+		return nil, err
+	}
+	if o == "" {
+		if config.GetAccAddress() == nil {
+			return nil, fmt.Errorf("%w, pass --%s", errNoOwner, flagOwner)
+		}
+		return config.GetAccAddress(), nil
+	}
+	owner, err := sdk.AccAddressFromBech32(o)
+	if err != nil {
+		return nil, fmt.Errorf("invalid owner %q: %w", o, err)
+	}
+	return owner, nil
+}
+
+func printJSON(v interface{}) error {
+	bz, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(bz))
+	return nil
+}
+
+func printArchivedDeployments(list []*ArchivedDeployment) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "DSEQ\tOWNER\tSTATE\tREVISIONS\tLEASES\tCREATED\tUPDATED")
+	for _, a := range list {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			a.DSeq, a.Owner, a.State(), len(a.Revisions), len(a.Leases), formatTime(&a.Created), formatTime(&a.Updated))
+	}
+	return w.Flush()
+}
+
+// Print prints the revisions, txs and leases of the archived deployment
+func (a *ArchivedDeployment) Print() error {
+	fmt.Printf("deployment %s/%d is %s\n", a.Owner, a.DSeq, a.State())
+	fmt.Printf("created %s, updated %s, closed %s\n", formatTime(&a.Created), formatTime(&a.Updated), formatTime(a.Closed))
+	fmt.Printf("archived in %s\n\n", a.dir)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tVERSION\tCREATED\tNOTE")
+	for _, r := range a.Revisions {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.Revision, shortVersion(r.Version), formatTime(&r.Created), r.Note)
+	}
+	fmt.Fprintln(w)
+
+	if len(a.Txs) > 0 {
+		fmt.Fprintln(w, "TX\tACTION\tREVISION\tTIME")
+		for _, tx := range a.Txs {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", tx.Hash, tx.Action, tx.Revision, formatTime(&tx.Time))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(a.Leases) > 0 {
+		fmt.Fprintln(w, "GSEQ/OSEQ\tPROVIDER\tPRICE\tSTATE\tSERVICES")
+		for _, l := range a.Leases {
+			state := archiveStateActive
+			if l.Closed != nil {
+				state = archiveStateClosed
+			}
+			services := make([]string, 0, len(l.Services))
+			for _, s := range l.Services {
+				services = append(services, fmt.Sprintf("%s=%s", s.Name, strings.Join(s.URIs, ",")))
+			}
+			fmt.Fprintf(w, "%d/%d\t%s\t%s\t%s\t%s\n", l.GSeq, l.OSeq, l.Provider, l.Price, state, strings.Join(services, " "))
+		}
+	}
+	return w.Flush()
+}
+
+// formatTime formats the time in the local timezone, or - if it is nil
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// shortVersion returns the start of a manifest version hash, enough to tell them apart
+func shortVersion(v string) string {
+	if len(v) > 12 {
+		return v[:12]
+	}
+	return v
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tendermint/tendermint/libs/bech32"
+)
+
+// captureStdout returns what f prints to stdout
+func captureStdout(t *testing.T, f func() error) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = f()
+	os.Stdout = stdout
+	w.Close()
+	out, _ := ioutil.ReadAll(r)
+	return string(out), err
+}
+
+func TestHistoryList(t *testing.T) {
+	home, err := ioutil.TempDir("", "deploy-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	owner, err := bech32.ConvertAndEncode(akashPrefix, testAddress("owner"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string
+		err  string
+	}{
+		{"every owner without a key", []string{"-o", "json"}, "[]", ""},
+		{"owner", []string{"-o", "json", "--owner", owner}, "[]", ""},
+		{"invalid owner", []string{"--owner", "notanaddress"}, "", `invalid owner "notanaddress"`},
+		{"dseq without a key", []string{"10"}, "", "no key loaded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := captureStdout(t, func() error {
+				return execute(append([]string{"--home", home, "history"}, tt.args...)...)
+			})
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.TrimSpace(out) != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, out)
+			}
+		})
+	}
+}
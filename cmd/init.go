@@ -26,21 +26,37 @@ import (
 var initCmd = &cobra.Command{
 	Use:   "init [chain-id] [rpc-addr]",
 	Short: "initialize the config file for the deploy application",
+	Long:  "initialize the config file with a single network, named by --network or \"default\". use `deploy network add` to add more networks",
 	Args:  cobra.ExactArgs(2),
+	Annotations: map[string]string{
+		annotationNewNetwork: "true",
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if _, err := os.Stat(cfgPath); os.IsNotExist(err) {
+			name, err := cmd.Flags().GetString(flagNetwork)
+			if err != nil {
+				return err
+			}
+			if name == "" {
+				name = defaultNetwork
+			}
 			fmt.Printf("creating config %s...\n", cfgPath)
 			if err = writeConfig(cmd, &Config{
-				ChainID: args[0],
-				RPCAddr: args[1],
-				Keyfile: "key.priv",
-				Keypass: defaultPass,
+				DefaultNetwork: name,
+				Networks: map[string]*Network{
+					name: {
+						ChainID: args[0],
+						RPCAddr: args[1],
+						Keyfile: "key.priv",
+						Keypass: defaultPass,
+					},
+				},
 			}); err != nil {
 				return err
 			}
 			return nil
 		}
-		return fmt.Errorf("Config %s already exists", cfgPath)
+		return fmt.Errorf("Config %s already exists, use `deploy network add` to add another network", cfgPath)
 	},
 }
 
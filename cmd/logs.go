@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ovrclk/akash/provider/gateway"
+	dtypes "github.com/ovrclk/akash/x/deployment/types"
+	mtypes "github.com/ovrclk/akash/x/market/types"
+	pmodule "github.com/ovrclk/akash/x/provider"
+	pquery "github.com/ovrclk/akash/x/provider/query"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagFollow     = "follow"
+	flagTail       = "tail"
+	flagService    = "service"
+	flagTimestamps = "timestamps"
+)
+
+// logColors are cycled through to tell the services apart
+var logColors = []string{"\x1b[36m", "\x1b[33m", "\x1b[32m", "\x1b[35m", "\x1b[34m", "\x1b[31m"}
+
+func init() {
+	rootCmd.AddCommand(logsCmd())
+}
+
+// logsCmd represents the logs command
+func logsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logs [dseq]",
+		Short: "print the logs of the services of a deployment, streamed from the provider of each lease",
+		Long: `print the logs of the services of a deployment, streamed from the provider of each lease
+
+each line is prefixed with its service and replica. providers don't send timestamps,
+--timestamps prints the time each line was received. without --follow the command
+exits once every provider has sent the logs it has`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dseq, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid dseq %q: %w", args[0], err)
+			}
+			owner, err := ownerFromFlags(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			opts := logsOptions{}
+			if opts.follow, err = cmd.Flags().GetBool(flagFollow); err != nil {
+				return err
+			}
+			if opts.tail, err = cmd.Flags().GetInt64(flagTail); err != nil {
+				return err
+			}
+			if opts.tail < -1 {
+				return fmt.Errorf("invalid --%s %d, use -1 for all lines", flagTail, opts.tail)
+			}
+			if opts.services, err = cmd.Flags().GetStringSlice(flagService); err != nil {
+				return err
+			}
+			if opts.timestamps, err = cmd.Flags().GetBool(flagTimestamps); err != nil {
+				return err
+			}
+			noColor, err := cmd.Flags().GetBool(flagNoColor)
+			if err != nil {
+				return err
+			}
+			opts.color = !noColor && config.Output != "json" && isTerminal(os.Stdout)
+
+			leases, err := config.ActiveLeases(dtypes.DeploymentID{Owner: owner, DSeq: dseq})
+			if err != nil {
+				return err
+			}
+			if len(leases) == 0 {
+				return fmt.Errorf("deployment %s/%d has no active leases", owner, dseq)
+			}
+			ctx, client := context.Background(), gateway.NewClient()
+			pclient := pmodule.AppModuleBasic{}.GetQueryClient(config.CLICtx(config.NewTMClient()))
+			streams, err := logStreams(ctx, client, pclient, leases, opts.services)
+			if err != nil {
+				return err
+			}
+			return streamLogs(ctx, os.Stdout, client, streams, opts)
+		},
+	}
+	cmd.Flags().String(flagOwner, "", "owner of the deployment, defaults to the configured key")
+	cmd.Flags().BoolP(flagFollow, "f", false, "keep streaming new lines")
+	cmd.Flags().Int64(flagTail, -1, "number of lines to print from the end of the logs of each replica, -1 for all")
+	cmd.Flags().StringSlice(flagService, nil, "only print the logs of this service, can be repeated")
+	cmd.Flags().Bool(flagTimestamps, false, "prefix lines with the time they were received")
+	cmd.Flags().Bool(flagNoColor, false, "don't colour the service prefixes")
+	return cmd
+}
+
+// logsOptions are the flags of the logs command
+type logsOptions struct {
+	follow     bool
+	tail       int64
+	services   []string
+	timestamps bool
+	color      bool
+}
+
+// logLine is a line of the logs of a service replica
+type logLine struct {
+	Provider string    `json:"provider"`
+	GSeq     uint32    `json:"gseq"`
+	Service  string    `json:"service"`
+	Replica  string    `json:"replica"`
+	Time     time.Time `json:"time"`
+	Message  string    `json:"message"`
+}
+
+// logStream is the logs of a service on the provider of a lease
+type logStream struct {
+	host    string
+	lid     mtypes.LeaseID
+	service string
+}
+
+// streamLogs streams the logs of the services from their providers and prints them
+// to w as they arrive, until every stream is closed or ctx is done
+func streamLogs(ctx context.Context, w io.Writer, client gateway.Client, streams []logStream, opts logsOptions) (err error) {
+	colors := make(map[string]string)
+	for _, s := range streams {
+		if _, ok := colors[s.service]; !ok {
+			colors[s.service] = logColors[len(colors)%len(logColors)]
+		}
+	}
+
+	var (
+		wg    sync.WaitGroup
+		lines = make(chan logLine)
+		errs  = make(chan error, len(streams))
+	)
+	for _, s := range streams {
+		wg.Add(1)
+		go func(s logStream) {
+			defer wg.Done()
+			logs, err := client.ServiceLogs(ctx, s.host, s.lid, s.service, opts.follow, opts.tail)
+			if err != nil {
+				errs <- fmt.Errorf("streaming logs of %s from %s: %w", s.service, s.lid.Provider, err)
+				return
+			}
+			for msg := range logs.Stream {
+				lines <- logLine{
+					Provider: s.lid.Provider.String(),
+					GSeq:     s.lid.GSeq,
+					Service:  s.service,
+					Replica:  msg.Name,
+					Time:     time.Now(),
+					Message:  msg.Message,
+				}
+			}
+		}(s)
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+		close(errs)
+	}()
+
+	enc := json.NewEncoder(w)
+	for l := range lines {
+		if config.Output == "json" {
+			if err = enc.Encode(l); err != nil {
+				return err
+			}
+			continue
+		}
+		l.Print(w, colors[l.Service], opts)
+	}
+
+	// every stream failing is an error, some failing is logged
+	failed := 0
+	for err := range errs {
+		logger.Error("error streaming logs", "err", err)
+		failed++
+	}
+	if failed == len(streams) {
+		return fmt.Errorf("couldn't stream the logs of any service")
+	}
+	return nil
+}
+
+// logStreams returns the services of each lease to stream, as reported by their providers,
+// filtered by services if it isn't empty
+func logStreams(ctx context.Context, client gateway.Client, pclient pquery.Client, leases []mtypes.LeaseID, services []string) ([]logStream, error) {
+	var (
+		streams []logStream
+		found   = make(map[string]bool)
+	)
+	for _, lid := range leases {
+		p, err := pclient.Provider(lid.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("looking up provider %s: %w", lid.Provider, err)
+		}
+		status, err := client.LeaseStatus(ctx, p.HostURI, lid)
+		if err != nil {
+			return nil, fmt.Errorf("querying lease status from %s: %w", lid.Provider, err)
+		}
+		names := make([]string, 0, len(status.Services))
+		for _, svc := range status.Services {
+			if len(services) == 0 || contains(services, svc.Name) {
+				names = append(names, svc.Name)
+				found[svc.Name] = true
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			streams = append(streams, logStream{host: p.HostURI, lid: lid, service: name})
+		}
+	}
+	for _, name := range services {
+		if !found[name] {
+			return nil, fmt.Errorf("no lease runs service %q", name)
+		}
+	}
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("the providers report no services for the leases")
+	}
+	return streams, nil
+}
+
+// Print prints the line to w prefixed with its service and replica
+func (l logLine) Print(w io.Writer, color string, opts logsOptions) {
+	prefix := l.Service + "/" + l.Replica
+	if opts.color {
+		prefix = color + prefix + colorReset
+	}
+	if opts.timestamps {
+		prefix = l.Time.Format(time.RFC3339) + " " + prefix
+	}
+	fmt.Fprintf(w, "%s | %s\n", prefix, l.Message)
+}
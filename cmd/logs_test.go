@@ -0,0 +1,289 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/gorilla/websocket"
+	"github.com/ovrclk/akash/provider/cluster"
+	"github.com/ovrclk/akash/provider/gateway"
+	mtypes "github.com/ovrclk/akash/x/market/types"
+	pquery "github.com/ovrclk/akash/x/provider/query"
+)
+
+// fakeGateway serves the lease status and service logs endpoints of a provider gateway
+type fakeGateway struct {
+	// services are the services reported for the leases of each group, other groups fail
+	services map[uint32][]string
+	// logs are the lines sent for each service, services without lines fail
+	logs map[string][]gateway.ServiceLogMessage
+
+	mu      sync.Mutex
+	queries map[string]url.Values
+}
+
+func newFakeGateway(t *testing.T, services map[uint32][]string, logs map[string][]gateway.ServiceLogMessage) (*fakeGateway, string) {
+	g := &fakeGateway{services: services, logs: logs, queries: make(map[string]url.Values)}
+	srv := httptest.NewServer(g)
+	t.Cleanup(srv.Close)
+	return g, srv.URL
+}
+
+// ServeHTTP handles /lease/{owner}/{dseq}/{gseq}/{oseq}/{provider}/status and .../service/{name}/logs
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/lease/"), "/")
+	if len(parts) < 6 {
+		http.NotFound(w, r)
+		return
+	}
+	gseq, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case len(parts) == 6 && parts[5] == "status":
+		names, ok := g.services[uint32(gseq)]
+		if !ok {
+			http.Error(w, "lease not found", http.StatusNotFound)
+			return
+		}
+		status := cluster.LeaseStatus{}
+		for _, name := range names {
+			status.Services = append(status.Services, &cluster.ServiceStatus{Name: name, Available: 1, Total: 1})
+		}
+		_ = json.NewEncoder(w).Encode(status)
+	case len(parts) == 8 && parts[5] == "service" && parts[7] == "logs":
+		lines, ok := g.logs[parts[6]]
+		if !ok {
+			http.Error(w, "service failed", http.StatusInternalServerError)
+			return
+		}
+		g.mu.Lock()
+		g.queries[parts[6]] = r.URL.Query()
+		g.mu.Unlock()
+
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for _, l := range lines {
+			if err = conn.WriteJSON(l); err != nil {
+				return
+			}
+		}
+		_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (g *fakeGateway) query(service string) url.Values {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.queries[service]
+}
+
+// fakeProviders looks up the host uri of providers from a map of addresses
+type fakeProviders map[string]string
+
+func (f fakeProviders) Providers() (pquery.Providers, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f fakeProviders) Provider(addr sdk.AccAddress) (*pquery.Provider, error) {
+	host, ok := f[addr.String()]
+	if !ok {
+		return nil, fmt.Errorf("provider %s not found", addr)
+	}
+	return &pquery.Provider{Owner: addr, HostURI: host}, nil
+}
+
+func TestLogStreams(t *testing.T) {
+	_, host := newFakeGateway(t, map[uint32][]string{
+		1: {"web", "db"},
+		2: {"worker"},
+		3: {},
+	}, nil)
+	p1, p2 := testAddress("provider1"), testAddress("provider2")
+	providers := fakeProviders{p1.String(): host, p2.String(): host}
+
+	tests := []struct {
+		name     string
+		leases   []mtypes.LeaseID
+		services []string
+		want     []string
+		err      string
+	}{
+		{
+			name:   "all services sorted by lease",
+			leases: []mtypes.LeaseID{testLease(1, p1), testLease(2, p2)},
+			want:   []string{"1/db", "1/web", "2/worker"},
+		},
+		{
+			name:     "filtered by service",
+			leases:   []mtypes.LeaseID{testLease(1, p1), testLease(2, p2)},
+			services: []string{"web", "worker"},
+			want:     []string{"1/web", "2/worker"},
+		},
+		{
+			name:     "unknown service",
+			leases:   []mtypes.LeaseID{testLease(1, p1)},
+			services: []string{"cache"},
+			err:      `no lease runs service "cache"`,
+		},
+		{
+			name:   "no services",
+			leases: []mtypes.LeaseID{testLease(3, p1)},
+			err:    "the providers report no services for the leases",
+		},
+		{
+			name:   "lease status fails",
+			leases: []mtypes.LeaseID{testLease(4, p1)},
+			err:    "querying lease status",
+		},
+		{
+			name:   "unknown provider",
+			leases: []mtypes.LeaseID{testLease(1, testAddress("provider3"))},
+			err:    "looking up provider",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streams, err := logStreams(context.Background(), gateway.NewClient(), providers, tt.leases, tt.services)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range streams {
+				if s.host != host {
+					t.Errorf("stream %s has host %s, expected %s", s.service, s.host, host)
+				}
+				got = append(got, fmt.Sprintf("%d/%s", s.lid.GSeq, s.service))
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("expected streams %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStreamLogs(t *testing.T) {
+	g, host := newFakeGateway(t, nil, map[string][]gateway.ServiceLogMessage{
+		"web": {{Name: "web-0", Message: "listening"}, {Name: "web-1", Message: "listening"}, {Name: "web-0", Message: "GET /"}},
+		"db":  {{Name: "db-0", Message: "ready"}},
+	})
+	lid := testLease(1, testAddress("provider1"))
+	stream := func(service string) logStream {
+		return logStream{host: host, lid: lid, service: service}
+	}
+
+	tests := []struct {
+		name    string
+		output  string
+		streams []logStream
+		opts    logsOptions
+		want    map[string][]string
+		err     string
+	}{
+		{
+			name:    "multiplexed",
+			streams: []logStream{stream("web"), stream("db")},
+			opts:    logsOptions{tail: -1},
+			want: map[string][]string{
+				"web": {"web/web-0 | listening", "web/web-1 | listening", "web/web-0 | GET /"},
+				"db":  {"db/db-0 | ready"},
+			},
+		},
+		{
+			name:    "partial failure",
+			streams: []logStream{stream("web"), stream("cache")},
+			opts:    logsOptions{tail: 10, follow: true},
+			want: map[string][]string{
+				"web": {"web/web-0 | listening", "web/web-1 | listening", "web/web-0 | GET /"},
+			},
+		},
+		{
+			name:    "total failure",
+			streams: []logStream{stream("cache"), stream("queue")},
+			opts:    logsOptions{tail: -1},
+			err:     "couldn't stream the logs of any service",
+		},
+		{
+			name:    "json",
+			output:  "json",
+			streams: []logStream{stream("db")},
+			opts:    logsOptions{tail: -1},
+			want: map[string][]string{
+				"db": {"db/db-0 | ready"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config = &Config{Network: Network{Output: tt.output}}
+			buf := &bytes.Buffer{}
+			err := streamLogs(context.Background(), buf, gateway.NewClient(), tt.streams, tt.opts)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// lines of different services interleave, each service keeps its order
+			got := make(map[string][]string)
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				if tt.output == "json" {
+					var l logLine
+					if err = json.Unmarshal([]byte(line), &l); err != nil {
+						t.Fatalf("invalid json line %q: %v", line, err)
+					}
+					line = l.Service + "/" + l.Replica + " | " + l.Message
+				}
+				service := strings.SplitN(line, "/", 2)[0]
+				got[service] = append(got[service], line)
+			}
+			for service, lines := range tt.want {
+				if strings.Join(got[service], "\n") != strings.Join(lines, "\n") {
+					t.Errorf("expected %s lines %q, got %q", service, lines, got[service])
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("expected lines of %d services, got %v", len(tt.want), got)
+			}
+
+			for _, s := range tt.streams {
+				q := g.query(s.service)
+				if q == nil {
+					continue
+				}
+				if q.Get("follow") != strconv.FormatBool(tt.opts.follow) || q.Get("tail") != strconv.FormatInt(tt.opts.tail, 10) {
+					t.Errorf("expected follow=%t tail=%d for %s, got %v", tt.opts.follow, tt.opts.tail, s.service, q)
+				}
+			}
+		})
+	}
+}
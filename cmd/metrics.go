@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ovrclk/akash/provider/gateway"
+	"github.com/ovrclk/akash/pubsub"
+	dmodule "github.com/ovrclk/akash/x/deployment"
+	dquery "github.com/ovrclk/akash/x/deployment/query"
+	"github.com/ovrclk/akash/x/market"
+	mquery "github.com/ovrclk/akash/x/market/query"
+	mtypes "github.com/ovrclk/akash/x/market/types"
+	pmodule "github.com/ovrclk/akash/x/provider"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/fsnotify.v1"
+)
+
+var (
+	flagMetricsListen   = "metrics-listen"
+	flagMetricsInterval = "metrics-interval"
+)
+
+// metricsStatusTimeout is how long to wait for a provider to report the status of a lease
+const metricsStatusTimeout = 10 * time.Second
+
+// metrics is the registry served on /metrics
+var metrics = prometheus.NewRegistry()
+
+var (
+	metricEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "deploy_events_total",
+		Help: "Chain and config directory events received, by type.",
+	}, []string{"type"})
+	metricHandlerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "deploy_handler_errors_total",
+		Help: "Errors returned by event handlers.",
+	}, []string{"handler"})
+	metricHandlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "deploy_handler_duration_seconds",
+		Help:    "Time event handlers took to handle an event.",
+		Buckets: prometheus.ExponentialBuckets(0.001, 4, 8),
+	}, []string{"handler"})
+	metricTxBroadcasts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "deploy_tx_broadcasts_total",
+		Help: "Transactions broadcast, by CheckTx result code, \"error\" if the node couldn't be reached.",
+	}, []string{"codespace", "code"})
+	metricGasUsed = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "deploy_tx_gas_used_total",
+		Help: "Gas used by the transactions included in a block.",
+	})
+	metricRPCReconnects = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "deploy_rpc_reconnects_total",
+		Help: "Times the rpc endpoint was switched after it failed or became unhealthy.",
+	})
+	metricDeployments = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "deploy_active_deployments",
+		Help: "Active deployments of the configured key.",
+	})
+	metricLeases = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "deploy_active_leases",
+		Help: "Active leases of the configured key.",
+	})
+	metricBalance = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "deploy_account_balance",
+		Help: "Spendable coins of the configured key, in the base denom.",
+	}, []string{"denom"})
+	metricServiceAvailable = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "deploy_service_available_replicas",
+		Help: "Available replicas of a service, as reported by the provider of its lease.",
+	}, serviceLabels)
+	metricServiceTotal = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "deploy_service_total_replicas",
+		Help: "Replicas of a service, as reported by the provider of its lease.",
+	}, serviceLabels)
+)
+
+var serviceLabels = []string{"dseq", "gseq", "oseq", "provider", "service"}
+
+func init() {
+	metrics.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		metricEvents,
+		metricHandlerErrors,
+		metricHandlerDuration,
+		metricTxBroadcasts,
+		metricGasUsed,
+		metricRPCReconnects,
+		metricDeployments,
+		metricLeases,
+		metricBalance,
+		metricServiceAvailable,
+		metricServiceTotal,
+	)
+}
+
+// addMetricsFlags adds the flags of the metrics server to a long running command
+func addMetricsFlags(cmd *cobra.Command) {
+	cmd.Flags().String(flagMetricsListen, "", "address to serve prometheus metrics on /metrics, e.g. :9100, disabled by default")
+	cmd.Flags().Duration(flagMetricsInterval, 30*time.Second, "how often to poll the balance, deployments and leases for metrics")
+}
+
+// metricsFlags returns the address to serve metrics on, empty if disabled, and the poll interval
+func metricsFlags(cmd *cobra.Command) (string, time.Duration, error) {
+	listen, err := cmd.Flags().GetString(flagMetricsListen)
+	if err != nil {
+		return "", 0, err
+	}
+	interval, err := cmd.Flags().GetDuration(flagMetricsInterval)
+	if err != nil {
+		return "", 0, err
+	}
+	if interval <= 0 {
+		return "", 0, fmt.Errorf("invalid --%s %s", flagMetricsInterval, interval)
+	}
+	return listen, interval, nil
+}
+
+// eventType names the type of an event of the bus
+func eventType(ev pubsub.Event) string {
+	switch ev.(type) {
+	case fsnotify.Event:
+		return "FSEvent"
+	case error:
+		return "Error"
+	default:
+		return reflect.TypeOf(ev).Name()
+	}
+}
+
+// CountEvents is an EventHandler counting events by type
+func CountEvents(ev pubsub.Event) error {
+	metricEvents.WithLabelValues(eventType(ev)).Inc()
+	return nil
+}
+
+// InstrumentHandler records how long the handler takes and the errors it returns under name
+func InstrumentHandler(name string, h EventHandler) EventHandler {
+	return func(ev pubsub.Event) error {
+		start := time.Now()
+		err := h(ev)
+		metricHandlerDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+		if err != nil {
+			metricHandlerErrors.WithLabelValues(name).Inc()
+		}
+		return err
+	}
+}
+
+// observeBroadcast counts a broadcast transaction by its CheckTx result
+func observeBroadcast(res sdk.TxResponse, err error) {
+	if err != nil {
+		metricTxBroadcasts.WithLabelValues("", "error").Inc()
+		return
+	}
+	metricTxBroadcasts.WithLabelValues(res.Codespace, strconv.FormatUint(uint64(res.Code), 10)).Inc()
+}
+
+// serveMetrics serves the metrics on /metrics of listen and polls the account, its
+// deployments and the status of its leases every interval, until ctx is done
+func serveMetrics(ctx context.Context, listen string, interval time.Duration) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(metrics, promhttp.HandlerOpts{}))
+	srv := &http.Server{Addr: listen, Handler: mux}
+
+	group, ctx := errgroup.WithContext(ctx)
+	group.Go(func() error {
+		logger.Info("serving metrics", "listen", listen)
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	})
+	group.Go(func() error {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(sctx)
+	})
+	group.Go(func() error {
+		owner := config.GetAccAddress()
+		if owner == nil {
+			logger.Info("no key loaded, not polling the account and its leases for metrics")
+			return nil
+		}
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			pollMetrics(ctx, owner)
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-tick.C:
+			}
+		}
+	})
+	return group.Wait()
+}
+
+// pollMetrics updates the balance, active deployments and leases of the owner and the
+// replicas of the services of its leases. Errors are logged and leave the previous values
+func pollMetrics(ctx context.Context, owner sdk.AccAddress) {
+	log := logger.With("metrics", "poll")
+	cctx := config.CLICtx(config.NewTMClient())
+
+	if bal, err := config.Balance(owner); err != nil {
+		log.Error("couldn't query the balance", "err", err)
+	} else {
+		metricBalance.Reset()
+		for _, coin := range bal.Spendable {
+			amount, _ := new(big.Float).SetInt(coin.Amount.BigInt()).Float64()
+			metricBalance.WithLabelValues(coin.Denom).Set(amount)
+		}
+	}
+
+	deployments, err := dmodule.AppModuleBasic{}.GetQueryClient(cctx).Deployments(dquery.DeploymentFilters{
+		Owner:        owner,
+		StateFlagVal: "active",
+	})
+	if err != nil {
+		log.Error("couldn't query the deployments", "err", err)
+	} else {
+		metricDeployments.Set(float64(len(deployments)))
+	}
+
+	leases, err := market.AppModuleBasic{}.GetQueryClient(cctx).Leases(mquery.LeaseFilters{
+		Owner: owner,
+		State: mtypes.LeaseActive,
+	})
+	if err != nil {
+		log.Error("couldn't query the leases", "err", err)
+		return
+	}
+	metricLeases.Set(float64(len(leases)))
+
+	// collect the statuses first so a scrape doesn't see the services half updated
+	type serviceReplicas struct {
+		labels           []string
+		available, total int32
+	}
+	var (
+		services []serviceReplicas
+		pclient  = pmodule.AppModuleBasic{}.GetQueryClient(cctx)
+		client   = gateway.NewClient()
+	)
+	for _, l := range leases {
+		lid := l.LeaseID
+		p, err := pclient.Provider(lid.Provider)
+		if err != nil {
+			log.Error("couldn't look up provider", "provider", lid.Provider, "err", err)
+			continue
+		}
+		sctx, cancel := context.WithTimeout(ctx, metricsStatusTimeout)
+		status, err := client.LeaseStatus(sctx, p.HostURI, lid)
+		cancel()
+		if err != nil {
+			log.Error("couldn't query lease status", "dseq", lid.DSeq, "gseq", lid.GSeq, "oseq", lid.OSeq, "provider", lid.Provider, "err", err)
+			continue
+		}
+		for _, s := range status.Services {
+			services = append(services, serviceReplicas{
+				labels: []string{
+					strconv.FormatUint(lid.DSeq, 10),
+					strconv.FormatUint(uint64(lid.GSeq), 10),
+					strconv.FormatUint(uint64(lid.OSeq), 10),
+					lid.Provider.String(),
+					s.Name,
+				},
+				available: s.Available,
+				total:     s.Total,
+			})
+		}
+	}
+	metricServiceAvailable.Reset()
+	metricServiceTotal.Reset()
+	for _, s := range services {
+		metricServiceAvailable.WithLabelValues(s.labels...).Set(float64(s.available))
+		metricServiceTotal.WithLabelValues(s.labels...).Set(float64(s.total))
+	}
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagNetwork = "network"
+)
+
+func init() {
+	rootCmd.AddCommand(networkCmd())
+}
+
+// networkCmd represents the network command
+func networkCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "network",
+		Aliases: []string{"networks", "net"},
+		Short:   "manage the networks in the config file",
+		Annotations: map[string]string{
+			annotationLenient: "true",
+		},
+	}
+	cmd.AddCommand(
+		networkAddCmd(),
+		networkListCmd(),
+		networkUseCmd(),
+		networkRemoveCmd(),
+	)
+	return cmd
+}
+
+func networkAddCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "add [name] [chain-id] [rpc-addr]",
+		Short: "add a network to the config file",
+		Long:  "add a network to the config file. any other setting flags passed, e.g. --keyfile or --gas-prices, are saved with the network",
+		Args:  cobra.ExactArgs(3),
+		Annotations: map[string]string{
+			annotationNewNetwork: "true",
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, ok := config.Networks[args[0]]; ok {
+				return fmt.Errorf("network %q already exists", args[0])
+			}
+
+			// apply the setting flags to an empty network
+			n := &Config{}
+			for _, s := range settings {
+				val, ok := s.flagValue(cmd.Flags())
+				if !ok {
+					continue
+				}
+				if err := s.set(n, val); err != nil {
+					return fmt.Errorf("invalid %s: %w", s.key, err)
+				}
+			}
+			n.ChainID, n.RPCAddr = args[1], args[2]
+			if n.Keyfile == "" {
+				n.Keyfile = "key.priv"
+			}
+			if n.Keypass == "" {
+				n.Keypass = defaultPass
+			}
+
+			if config.Networks == nil {
+				config.Networks = make(map[string]*Network)
+			}
+			config.Networks[args[0]] = &n.Network
+			if config.DefaultNetwork == "" {
+				config.DefaultNetwork = args[0]
+			}
+			return writeConfig(cmd, config)
+		},
+	}
+}
+
+func networkListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "list the networks in the config file, the default is marked with *",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			names := make([]string, 0, len(config.Networks))
+			for name := range config.Networks {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(w, "\tNAME\tCHAIN-ID\tRPC-ADDR\tKEYFILE")
+			for _, name := range names {
+				n, mark := config.Networks[name], ""
+				if name == config.DefaultNetwork {
+					mark = "*"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", mark, name, n.ChainID, n.RPCAddr, n.Keyfile)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func networkUseCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "use [name]",
+		Short: "set the default network",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, ok := config.Networks[args[0]]; !ok {
+				return fmt.Errorf("network %q not found", args[0])
+			}
+			config.DefaultNetwork = args[0]
+			return writeConfig(cmd, config)
+		},
+	}
+}
+
+func networkRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:     "remove [name]",
+		Aliases: []string{"rm"},
+		Short:   "remove a network from the config file",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, ok := config.Networks[args[0]]; !ok {
+				return fmt.Errorf("network %q not found", args[0])
+			}
+			if args[0] == config.DefaultNetwork && len(config.Networks) > 1 {
+				return fmt.Errorf("network %q is the default, `deploy network use` another network first", args[0])
+			}
+			delete(config.Networks, args[0])
+			if len(config.Networks) == 0 {
+				config.DefaultNetwork = ""
+			}
+			return writeConfig(cmd, config)
+		},
+	}
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	mtypes "github.com/ovrclk/akash/x/market/types"
+	pmodule "github.com/ovrclk/akash/x/provider"
+	pquery "github.com/ovrclk/akash/x/provider/query"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// providerPolicyClose closes the order of a lease with a provider that isn't allowed so it is re-opened
+	providerPolicyClose = "close"
+	// providerPolicyFail stops the command when a lease is created with a provider that isn't allowed
+	providerPolicyFail = "fail"
+
+	// maxProviderRejections is how many leases of a group are closed before giving up
+	maxProviderRejections = 3
+)
+
+// errProviderNotAllowed is wrapped by the errors of providers a ProviderFilter doesn't allow
+var errProviderNotAllowed = errors.New("provider not allowed")
+
+// ProviderFilter lists the providers leases are allowed or denied with. Each
+// entry is a provider address or a key=value provider attribute
+type ProviderFilter struct {
+	Allow []string `yaml:"allow,omitempty" json:"allow,omitempty"`
+	Deny  []string `yaml:"deny,omitempty" json:"deny,omitempty"`
+}
+
+// sdlProviderFilter reads the providers key of an sdl, the akash sdl package ignores it
+func sdlProviderFilter(buf []byte) (ProviderFilter, error) {
+	var obj struct {
+		Providers ProviderFilter `yaml:"providers"`
+	}
+	err := yaml.Unmarshal(buf, &obj)
+	return obj.Providers, err
+}
+
+func (p ProviderFilter) empty() bool {
+	return len(p.Allow) == 0 && len(p.Deny) == 0
+}
+
+// check returns an error if the provider is denied, or the filter allows
+// some providers and it isn't one of them. source names the filter in errors
+func (p ProviderFilter) check(source string, provider *pquery.Provider) error {
+	for _, rule := range p.Deny {
+		if providerMatches(rule, provider) {
+			return fmt.Errorf("%w: %s is denied by %q in the %s", errProviderNotAllowed, provider.Owner, rule, source)
+		}
+	}
+	if len(p.Allow) == 0 {
+		return nil
+	}
+	for _, rule := range p.Allow {
+		if providerMatches(rule, provider) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s isn't one of %s allowed in the %s", errProviderNotAllowed, provider.Owner, strings.Join(p.Allow, ", "), source)
+}
+
+// providerMatches returns true if the rule is the provider's address or one of its key=value attributes
+func providerMatches(rule string, provider *pquery.Provider) bool {
+	kv := strings.SplitN(rule, "=", 2)
+	if len(kv) == 1 {
+		return provider.Owner.String() == rule
+	}
+	for _, a := range provider.Attributes {
+		if a.Key == kv[0] && a.Value == kv[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// validateProviderRule checks a rule is an address or a key=value attribute
+func validateProviderRule(rule string) error {
+	kv := strings.SplitN(rule, "=", 2)
+	if len(kv) == 2 && (kv[0] == "" || kv[1] == "") {
+		return fmt.Errorf("invalid provider attribute %q, expected key=value", rule)
+	}
+	if len(kv) == 1 && rule == "" {
+		return fmt.Errorf("empty provider")
+	}
+	return nil
+}
+
+// splitProviderRules splits a comma separated list of rules and validates them
+func splitProviderRules(v string) ([]string, error) {
+	rules := splitList(v)
+	for _, rule := range rules {
+		if err := validateProviderRule(rule); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
+}
+
+// CheckProvider returns an error if the provider isn't allowed by the config or the sdl.
+// the result is cached by provider, errors looking the provider up are not
+func (dd *DeploymentData) CheckProvider(addr sdk.AccAddress) error {
+	if config.Providers.empty() && dd.Providers.empty() {
+		return nil
+	}
+	dd.RLock()
+	err, ok := dd.checked[addr.String()]
+	dd.RUnlock()
+	if ok {
+		return err
+	}
+
+	pclient := pmodule.AppModuleBasic{}.GetQueryClient(config.CLICtx(config.NewTMClient()))
+	provider, err := pclient.Provider(addr)
+	if err != nil {
+		return fmt.Errorf("looking up provider %s to check it is allowed: %w", addr, err)
+	}
+	if err = config.Providers.check("config", provider); err == nil {
+		err = dd.Providers.check("sdl", provider)
+	}
+
+	dd.Lock()
+	defer dd.Unlock()
+	if dd.checked == nil {
+		dd.checked = make(map[string]error)
+	}
+	dd.checked[addr.String()] = err
+	return err
+}
+
+// RejectLease applies the provider policy to a lease with a provider that isn't allowed.
+// With the close policy the order is closed so the chain re-opens it for new bids,
+// otherwise, or once a group has been rejected maxProviderRejections times, the error is returned
+func (dd *DeploymentData) RejectLease(lid mtypes.LeaseID, reason error) error {
+	dd.Lock()
+	if dd.rejections == nil {
+		dd.rejections = make(map[uint32]int)
+	}
+	dd.rejections[lid.GSeq]++
+	n := dd.rejections[lid.GSeq]
+	dd.Unlock()
+
+	if config.ProviderPolicy != providerPolicyClose {
+		return fmt.Errorf("%w, not sending the manifest", reason)
+	}
+	if n > maxProviderRejections {
+		return fmt.Errorf("%w, giving up after closing %d leases of group %d", reason, maxProviderRejections, lid.GSeq)
+	}
+
+	log := logger.With("action", "close-order", "dseq", lid.DSeq, "gseq", lid.GSeq, "oseq", lid.OSeq)
+	res, err := config.SendMsgs([]sdk.Msg{mtypes.MsgCloseOrder{OrderID: mtypes.BidID(lid).OrderID()}})
+	if err != nil {
+		log.Error("tx failed", "hash", res.TxHash, "log", res.RawLog)
+		return fmt.Errorf("closing order of lease with provider that isn't allowed: %w", err)
+	}
+	log.Info("closed order of lease with provider that isn't allowed, it will be re-opened", "provider", lid.Provider, "hash", res.TxHash)
+	if err = dd.Archive.AddTx("close-order", res.TxHash); err != nil {
+		log.Error("error updating archive", "err", err)
+	}
+	return nil
+}
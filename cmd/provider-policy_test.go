@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	pquery "github.com/ovrclk/akash/x/provider/query"
+)
+
+func TestProviderFilterCheck(t *testing.T) {
+	addr := testAddress("provider1")
+	provider := &pquery.Provider{
+		Owner:      addr,
+		Attributes: []sdk.Attribute{{Key: "region", Value: "us-west"}, {Key: "tier", Value: "1"}},
+	}
+
+	tests := []struct {
+		name    string
+		filter  ProviderFilter
+		allowed bool
+	}{
+		{"empty", ProviderFilter{}, true},
+		{"allowed by address", ProviderFilter{Allow: []string{addr.String()}}, true},
+		{"allowed by attribute", ProviderFilter{Allow: []string{"region=eu", "region=us-west"}}, true},
+		{"not in allow list", ProviderFilter{Allow: []string{"region=eu"}}, false},
+		{"attribute value must match", ProviderFilter{Allow: []string{"tier=2"}}, false},
+		{"denied by address", ProviderFilter{Deny: []string{addr.String()}}, false},
+		{"denied by attribute", ProviderFilter{Deny: []string{"tier=1"}}, false},
+		{"deny not matching", ProviderFilter{Deny: []string{"region=eu", testAddress("provider2").String()}}, true},
+		{"deny wins over allow", ProviderFilter{Allow: []string{"region=us-west"}, Deny: []string{"tier=1"}}, false},
+		{"attribute key only isn't an address", ProviderFilter{Allow: []string{"region"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.check("config", provider)
+			if tt.allowed && err != nil {
+				t.Fatalf("expected provider to be allowed, got %v", err)
+			}
+			if !tt.allowed && !errors.Is(err, errProviderNotAllowed) {
+				t.Fatalf("expected errProviderNotAllowed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateProviderRule(t *testing.T) {
+	tests := []struct {
+		rule  string
+		valid bool
+	}{
+		{"akash1xyz", true},
+		{"region=us-west", true},
+		{"region=a=b", true},
+		{"", false},
+		{"=us-west", false},
+		{"region=", false},
+	}
+	for _, tt := range tests {
+		if err := validateProviderRule(tt.rule); (err == nil) != tt.valid {
+			t.Errorf("validateProviderRule(%q) = %v, expected valid %t", tt.rule, err, tt.valid)
+		}
+	}
+}
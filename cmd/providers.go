@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ovrclk/akash/provider"
+	"github.com/ovrclk/akash/provider/gateway"
+	pmodule "github.com/ovrclk/akash/x/provider"
+	pquery "github.com/ovrclk/akash/x/provider/query"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagAttr        = "attr"
+	flagPing        = "ping"
+	flagPingTimeout = "ping-timeout"
+)
+
+func init() {
+	rootCmd.AddCommand(providersCmd())
+}
+
+// providersCmd represents the providers command
+func providersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "providers",
+		Short: "browse the providers registered on the network",
+	}
+	cmd.AddCommand(
+		providersListCmd(),
+		providersShowCmd(),
+	)
+	return cmd
+}
+
+func providersListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "list providers with their host uri and attributes, filtered by --attr",
+		Long: `list providers with their host uri and attributes, filtered by --attr
+
+only providers with every --attr are listed, use the placement attributes of an
+sdl to check a provider will bid on it. with --ping the gateway of each provider
+is asked for its status`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filters, err := cmd.Flags().GetStringArray(flagAttr)
+			if err != nil {
+				return err
+			}
+			attrs, err := parseAttributes(filters)
+			if err != nil {
+				return err
+			}
+
+			pclient := pmodule.AppModuleBasic{}.GetQueryClient(config.CLICtx(config.NewTMClient()))
+			all, err := pclient.Providers()
+			if err != nil {
+				return err
+			}
+			var list []*providerInfo
+			for _, p := range all {
+				if hasAttributes(p, attrs) {
+					list = append(list, newProviderInfo(p))
+				}
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i].Owner < list[j].Owner })
+
+			if err = pingFromFlags(cmd, list); err != nil {
+				return err
+			}
+			if config.Output == "json" {
+				if list == nil {
+					list = []*providerInfo{}
+				}
+				return printJSON(list)
+			}
+			return printProviders(list)
+		},
+	}
+	cmd.Flags().StringArray(flagAttr, nil, "only list providers with the attribute key=value, can be repeated")
+	addPingFlags(cmd)
+	return cmd
+}
+
+func providersShowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show [addr]",
+		Short: "show a provider's host uri and attributes",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			addr, err := sdk.AccAddressFromBech32(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid provider address %q: %w", args[0], err)
+			}
+			pclient := pmodule.AppModuleBasic{}.GetQueryClient(config.CLICtx(config.NewTMClient()))
+			p, err := pclient.Provider(addr)
+			if err != nil {
+				return err
+			}
+			info := newProviderInfo(*p)
+			if err = pingFromFlags(cmd, []*providerInfo{info}); err != nil {
+				return err
+			}
+			if config.Output == "json" {
+				return printJSON(info)
+			}
+			info.Print()
+			return nil
+		},
+	}
+	addPingFlags(cmd)
+	return cmd
+}
+
+func addPingFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool(flagPing, false, "ask each provider's gateway for its status")
+	cmd.Flags().Duration(flagPingTimeout, 5*time.Second, "how long to wait for a gateway to answer")
+}
+
+// providerInfo is a registered provider and, if pinged, its gateway status
+type providerInfo struct {
+	Owner      string            `json:"owner"`
+	HostURI    string            `json:"host-uri"`
+	Attributes map[string]string `json:"attributes"`
+	Ping       *providerPing     `json:"ping,omitempty"`
+}
+
+// providerPing is the answer of a provider's gateway
+type providerPing struct {
+	Latency string           `json:"latency,omitempty"`
+	Error   string           `json:"error,omitempty"`
+	Status  *provider.Status `json:"status,omitempty"`
+}
+
+func newProviderInfo(p pquery.Provider) *providerInfo {
+	info := &providerInfo{
+		Owner:      p.Owner.String(),
+		HostURI:    p.HostURI,
+		Attributes: make(map[string]string, len(p.Attributes)),
+	}
+	for _, a := range p.Attributes {
+		info.Attributes[a.Key] = a.Value
+	}
+	return info
+}
+
+// parseAttributes parses key=value attributes
+func parseAttributes(pairs []string) ([]sdk.Attribute, error) {
+	attrs := make([]sdk.Attribute, 0, len(pairs))
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid attribute %q, expected key=value", pair)
+		}
+		attrs = append(attrs, sdk.NewAttribute(kv[0], kv[1]))
+	}
+	return attrs, nil
+}
+
+// hasAttributes returns true if the provider has every attribute
+func hasAttributes(p pquery.Provider, attrs []sdk.Attribute) bool {
+attrs:
+	for _, want := range attrs {
+		for _, have := range p.Attributes {
+			if have.Key == want.Key && have.Value == want.Value {
+				continue attrs
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// pingFromFlags pings the gateways of the providers concurrently if --ping is set
+func pingFromFlags(cmd *cobra.Command, list []*providerInfo) error {
+	ping, err := cmd.Flags().GetBool(flagPing)
+	if err != nil || !ping {
+		return err
+	}
+	timeout, err := cmd.Flags().GetDuration(flagPingTimeout)
+	if err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, info := range list {
+		wg.Add(1)
+		go func(info *providerInfo) {
+			defer wg.Done()
+			info.Ping = pingProvider(info.HostURI, timeout)
+		}(info)
+	}
+	wg.Wait()
+	return nil
+}
+
+// pingProvider asks the provider's gateway for its status
+func pingProvider(hostURI string, timeout time.Duration) *providerPing {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	status, err := gateway.NewClient().Status(ctx, hostURI)
+	if err != nil {
+		return &providerPing{Error: err.Error()}
+	}
+	return &providerPing{
+		Latency: time.Since(start).Round(time.Millisecond).String(),
+		Status:  status,
+	}
+}
+
+func printProviders(list []*providerInfo) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	pinged := len(list) > 0 && list[0].Ping != nil
+	if pinged {
+		fmt.Fprintln(w, "OWNER\tHOST-URI\tATTRIBUTES\tPING")
+	} else {
+		fmt.Fprintln(w, "OWNER\tHOST-URI\tATTRIBUTES")
+	}
+	for _, p := range list {
+		if pinged {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", p.Owner, p.HostURI, p.formatAttributes(), p.Ping)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", p.Owner, p.HostURI, p.formatAttributes())
+		}
+	}
+	return w.Flush()
+}
+
+// Print prints the provider and the status of its gateway, if pinged
+func (p *providerInfo) Print() {
+	fmt.Printf("owner:      %s\n", p.Owner)
+	fmt.Printf("host-uri:   %s\n", p.HostURI)
+	fmt.Printf("attributes: %s\n", p.formatAttributes())
+	if p.Ping == nil {
+		return
+	}
+	fmt.Printf("ping:       %s\n", p.Ping)
+	if s := p.Ping.Status; s != nil && s.Cluster != nil {
+		fmt.Printf("leases:     %d\n", s.Cluster.Leases)
+		inv := s.Cluster.Inventory
+		fmt.Printf("inventory:  %d active, %d pending, %d available units\n",
+			len(inv.Active), len(inv.Pending), len(inv.Available))
+	}
+}
+
+// formatAttributes returns the attributes as sorted key=value pairs
+func (p *providerInfo) formatAttributes() string {
+	pairs := make([]string, 0, len(p.Attributes))
+	for k, v := range p.Attributes {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (p *providerPing) String() string {
+	if p.Error != "" {
+		return "unreachable: " + p.Error
+	}
+	return "ok " + p.Latency
+}
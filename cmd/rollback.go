@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ovrclk/akash/manifest"
+	"github.com/ovrclk/akash/provider/cluster"
+	"github.com/ovrclk/akash/provider/gateway"
+	dmodule "github.com/ovrclk/akash/x/deployment"
+	dtypes "github.com/ovrclk/akash/x/deployment/types"
+	mtypes "github.com/ovrclk/akash/x/market/types"
+	pmodule "github.com/ovrclk/akash/x/provider"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd())
+}
+
+// rollbackCmd represents the rollback command
+func rollbackCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rollback [dseq] [revision]",
+		Short: "update a deployment to an earlier sdl revision from the archive, the previous one by default",
+		Long: `update a deployment to an earlier sdl revision from the archive, the previous one by default
+
+the deployment version is updated on chain, the manifest of the revision is sent to
+the provider of every active lease and the services are polled until they run the
+revision, or --timeout passes. the rollback is archived as a new revision, see ` + "`deploy history [dseq]`" + `.
+only the manifest can be rolled back, resources and pricing are fixed when the
+deployment is created`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := config.SetGasOnConfig(); err != nil {
+				return err
+			}
+			if config.GetAccAddress() == nil {
+				return fmt.Errorf("no key loaded, create one with `deploy key-add`")
+			}
+			dseq, err := strconv.ParseUint(args[0], 10, 64)
+			if err != nil {
+				return fmt.Errorf("invalid dseq %q: %w", args[0], err)
+			}
+			log := logger.With("cli", "rollback", "dseq", dseq)
+
+			a, err := config.OpenArchivedDeployment(config.GetAccAddress().String(), dseq)
+			if err != nil {
+				return err
+			}
+			if a.Closed != nil {
+				return fmt.Errorf("deployment %d was closed on %s", dseq, formatTime(a.Closed))
+			}
+			latest := a.Latest()
+			rev := latest.Revision - 1
+			if len(args) > 1 {
+				if rev, err = strconv.Atoi(args[1]); err != nil {
+					return fmt.Errorf("invalid revision %q: %w", args[1], err)
+				}
+			}
+			if rev < 1 {
+				return fmt.Errorf("deployment %d has no earlier revision to roll back to", dseq)
+			}
+			if rev == latest.Revision {
+				return fmt.Errorf("revision %d is the latest revision", rev)
+			}
+
+			_, buf, err := a.Revision(rev)
+			if err != nil {
+				return err
+			}
+			dd, err := DeploymentDataFromSDL(buf)
+			if err != nil {
+				return fmt.Errorf("revision %d: %w", rev, err)
+			}
+			dd.DeploymentID = dtypes.DeploymentID{Owner: config.GetAccAddress(), DSeq: dseq}
+			dd.Archive = a
+			warnGroupsChanged(a, latest.Revision, dd)
+
+			// the manifest is sent to the providers of the existing leases
+			leases, err := config.ActiveLeases(dd.DeploymentID)
+			if err != nil {
+				return err
+			}
+			if len(leases) == 0 {
+				return fmt.Errorf("deployment %d has no active leases to send the manifest to", dseq)
+			}
+			for _, l := range leases {
+				dd.AddLease(l)
+			}
+
+			dclient := dmodule.AppModuleBasic{}.GetQueryClient(config.CLICtx(config.NewTMClient()))
+			d, err := dclient.Deployment(dd.DeploymentID)
+			if err != nil {
+				return err
+			}
+			note := fmt.Sprintf("rollback to revision %d", rev)
+			if bytes.Equal(d.Version, dd.Version) {
+				log.Info("deployment already has the version of the revision, not updating it", "version", hex.EncodeToString(dd.Version))
+				if _, err = a.AddRevision(dd.SDLFile, dd.Version, note); err != nil {
+					return err
+				}
+			} else if err = config.TxUpdateDeployment(dd, note); err != nil {
+				return err
+			}
+
+			// the services are rolled out once the providers run the sent manifest, which
+			// is only visible as a new generation of the services' kubernetes deployments
+			changed, err := changedServices(a, latest.Revision, rev)
+			if err != nil {
+				return err
+			}
+			rollouts, err := leaseRollouts(dd.Leases())
+			if err != nil {
+				return err
+			}
+
+			for _, l := range dd.Leases() {
+				if err = config.SendManifest(dd, l); err != nil {
+					return fmt.Errorf("sending manifest to %s: %w", l.Provider, err)
+				}
+			}
+
+			timeout, err := cmd.Flags().GetDuration(flagTimeout)
+			if err != nil {
+				return err
+			}
+			if err = waitForRollout(context.Background(), gateway.NewClient(), rollouts, changed, timeout); err != nil {
+				printDeploymentEvents(dd.Leases())
+				return err
+			}
+			log.Info("rolled back", "revision", rev)
+			return nil
+		},
+	}
+	cmd.Flags().Duration(flagTimeout, 5*time.Minute, "how long to wait for the services to run the revision")
+	return cmd
+}
+
+// rolloutPollInterval is how often the services are polled while they are rolled out
+var rolloutPollInterval = 2 * time.Second
+
+// leaseRollout is a lease the manifest is sent to, with the status of its services before
+type leaseRollout struct {
+	lid    mtypes.LeaseID
+	host   string
+	before map[string]*cluster.ServiceStatus
+}
+
+// leaseRollouts looks up the providers of the leases and the current status of their services
+func leaseRollouts(leases []mtypes.LeaseID) ([]*leaseRollout, error) {
+	pclient := pmodule.AppModuleBasic{}.GetQueryClient(config.CLICtx(config.NewTMClient()))
+	var out []*leaseRollout
+	for _, l := range leases {
+		p, err := pclient.Provider(l.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("looking up provider %s: %w", l.Provider, err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), metricsStatusTimeout)
+		before, err := serviceStatuses(ctx, gateway.NewClient(), p.HostURI, l)
+		cancel()
+		if err != nil {
+			// the services may not have been deployed, they are waited for all the same
+			logger.Debug("couldn't query the services before rolling back", "lease", l, "err", err)
+		}
+		out = append(out, &leaseRollout{lid: l, host: p.HostURI, before: before})
+	}
+	return out, nil
+}
+
+// serviceStatuses returns the status of every service of the lease, including the
+// generation and replica counts the lease status leaves out
+func serviceStatuses(ctx context.Context, client gateway.Client, host string, lid mtypes.LeaseID) (map[string]*cluster.ServiceStatus, error) {
+	ls, err := client.LeaseStatus(ctx, host, lid)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]*cluster.ServiceStatus, len(ls.Services))
+	for _, s := range ls.Services {
+		ss, err := client.ServiceStatus(ctx, host, lid, s.Name)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", s.Name, err)
+		}
+		ss.Name, ss.URIs = s.Name, s.URIs
+		out[s.Name] = ss
+	}
+	return out, nil
+}
+
+// changedServices returns the services whose kubernetes deployments change between the
+// revisions. Exposing ports differently only changes the routing, which isn't rolled out
+func changedServices(a *ArchivedDeployment, from, to int) (map[string]bool, error) {
+	var ms []manifest.Manifest
+	for _, rev := range []int{from, to} {
+		_, buf, err := a.Revision(rev)
+		if err != nil {
+			return nil, err
+		}
+		m, err := sdlManifest(buf)
+		if err != nil {
+			return nil, fmt.Errorf("revision %d: %w", rev, err)
+		}
+		ms = append(ms, m)
+	}
+	d, err := diffManifests(ms[0], ms[1])
+	if err != nil {
+		return nil, err
+	}
+	changed := make(map[string]bool)
+	for _, c := range d.Changes {
+		if c.Field != "expose" {
+			changed[c.Service] = true
+		}
+	}
+	return changed, nil
+}
+
+// rolloutPending returns the services that don't run the sent manifest yet. A changed
+// service has to be observed with a newer generation than before the manifest was sent,
+// and every service has to have all of its replicas updated and available
+func rolloutPending(before, after map[string]*cluster.ServiceStatus, changed map[string]bool) []string {
+	var pending []string
+	for name := range changed {
+		if _, ok := after[name]; !ok {
+			pending = append(pending, name)
+		}
+	}
+	for name, s := range after {
+		if b, ok := before[name]; ok && changed[name] && s.ObservedGeneration <= b.ObservedGeneration {
+			pending = append(pending, name)
+			continue
+		}
+		if s.UpdatedReplicas < s.Replicas || s.AvailableReplicas < s.Replicas {
+			pending = append(pending, name)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// waitForRollout polls the services of the leases until they run the sent manifest or the timeout passes
+func waitForRollout(ctx context.Context, client gateway.Client, rollouts []*leaseRollout, changed map[string]bool, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	tick := time.NewTicker(rolloutPollInterval)
+	defer tick.Stop()
+
+	done := make(map[*leaseRollout]bool)
+	for {
+		for _, r := range rollouts {
+			if done[r] {
+				continue
+			}
+			sctx, scancel := context.WithTimeout(ctx, metricsStatusTimeout)
+			after, err := serviceStatuses(sctx, client, r.host, r.lid)
+			scancel()
+			if err != nil {
+				logger.Debug("querying services", "lease", r.lid, "err", err)
+				continue
+			}
+			if pending := rolloutPending(r.before, after, changed); len(pending) > 0 {
+				logger.Debug("waiting for services to roll out", "lease", r.lid, "services", strings.Join(pending, ","))
+				continue
+			}
+			for _, s := range after {
+				logger.Info(strings.Join(s.URIs, ","), "name", s.Name, "available", s.AvailableReplicas)
+			}
+			done[r] = true
+		}
+		if len(done) == len(rollouts) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out after %s waiting for the services to run the revision, see `deploy events` for more", timeout)
+		case <-tick.C:
+		}
+	}
+}
+
+// warnGroupsChanged warns when the groups of the DeploymentData differ from those of the revision,
+// the chain keeps the groups the deployment was created with
+func warnGroupsChanged(a *ArchivedDeployment, rev int, dd *DeploymentData) {
+	_, buf, err := a.Revision(rev)
+	if err != nil {
+		return
+	}
+	current, err := DeploymentDataFromSDL(buf)
+	if err != nil {
+		return
+	}
+	if !reflect.DeepEqual(current.Groups, dd.Groups) {
+		logger.Info("resources or pricing differ from the deployed revision, only the manifest is rolled back and providers may reject it",
+			"deployed-revision", rev)
+	}
+}
+
+// TxUpdateDeployment updates the deployment to the version of the DeploymentData,
+// archiving its sdl as a new revision with the note
+func (c *Config) TxUpdateDeployment(dd *DeploymentData, note string) error {
+	res, err := c.SendMsgs([]sdk.Msg{dd.MsgUpdate()})
+	log := logger.With(
+		"hash", res.TxHash,
+		"code", res.Code,
+		"codespace", res.Codespace,
+		"action", "update-deployment",
+		"dseq", dd.DeploymentID.DSeq,
+	)
+
+	if err != nil {
+		log.Error("tx failed", "log", res.RawLog)
+		return err
+	}
+
+	log.Info("tx sent successfully")
+	if _, err = dd.Archive.AddRevision(dd.SDLFile, dd.Version, note); err != nil {
+		log.Error("error updating archive", "err", err)
+	}
+	if err = dd.Archive.AddTx("update-deployment", res.TxHash); err != nil {
+		log.Error("error updating archive", "err", err)
+	}
+	return nil
+}
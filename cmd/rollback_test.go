@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ovrclk/akash/provider/cluster"
+	"github.com/ovrclk/akash/provider/gateway"
+	mtypes "github.com/ovrclk/akash/x/market/types"
+)
+
+func testLease(gseq uint32, provider sdk.AccAddress) mtypes.LeaseID {
+	return mtypes.LeaseID{Owner: testAddress("owner"), DSeq: 10, GSeq: gseq, OSeq: 1, Provider: provider}
+}
+
+func TestRolloutPending(t *testing.T) {
+	status := func(gen int64, replicas, updated, available int32) *cluster.ServiceStatus {
+		return &cluster.ServiceStatus{ObservedGeneration: gen, Replicas: replicas, UpdatedReplicas: updated, AvailableReplicas: available}
+	}
+	tests := []struct {
+		name    string
+		before  map[string]*cluster.ServiceStatus
+		after   map[string]*cluster.ServiceStatus
+		changed map[string]bool
+		want    string
+	}{
+		{
+			name:   "unchanged and available",
+			before: map[string]*cluster.ServiceStatus{"web": status(1, 1, 1, 1)},
+			after:  map[string]*cluster.ServiceStatus{"web": status(1, 1, 1, 1)},
+		},
+		{
+			name:   "unchanged but unavailable",
+			before: map[string]*cluster.ServiceStatus{"web": status(1, 1, 1, 1)},
+			after:  map[string]*cluster.ServiceStatus{"web": status(1, 1, 1, 0)},
+			want:   "web",
+		},
+		{
+			name:    "old revision still running",
+			before:  map[string]*cluster.ServiceStatus{"web": status(1, 1, 1, 1)},
+			after:   map[string]*cluster.ServiceStatus{"web": status(1, 1, 1, 1)},
+			changed: map[string]bool{"web": true},
+			want:    "web",
+		},
+		{
+			name:    "rolling out",
+			before:  map[string]*cluster.ServiceStatus{"web": status(1, 2, 2, 2)},
+			after:   map[string]*cluster.ServiceStatus{"web": status(2, 3, 1, 2)},
+			changed: map[string]bool{"web": true},
+			want:    "web",
+		},
+		{
+			name:    "rolled out",
+			before:  map[string]*cluster.ServiceStatus{"web": status(1, 2, 2, 2)},
+			after:   map[string]*cluster.ServiceStatus{"web": status(2, 2, 2, 2)},
+			changed: map[string]bool{"web": true},
+		},
+		{
+			name:    "added service not deployed yet",
+			before:  map[string]*cluster.ServiceStatus{"web": status(1, 1, 1, 1)},
+			after:   map[string]*cluster.ServiceStatus{"web": status(1, 1, 1, 1)},
+			changed: map[string]bool{"db": true},
+			want:    "db",
+		},
+		{
+			name:    "added service deployed",
+			before:  map[string]*cluster.ServiceStatus{"web": status(1, 1, 1, 1)},
+			after:   map[string]*cluster.ServiceStatus{"web": status(1, 1, 1, 1), "db": status(1, 1, 1, 1)},
+			changed: map[string]bool{"db": true},
+		},
+		{
+			name:    "several pending",
+			after:   map[string]*cluster.ServiceStatus{"web": status(1, 1, 0, 1), "db": status(1, 1, 1, 0)},
+			changed: map[string]bool{"cache": true},
+			want:    "cache,db,web",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(rolloutPending(tt.before, tt.after, tt.changed), ","); got != tt.want {
+				t.Errorf("expected pending %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// fakeRollout serves the status of a web service that moves through the statuses, one per lease status query
+type fakeRollout struct {
+	statuses []*cluster.ServiceStatus
+
+	mu    sync.Mutex
+	polls int
+}
+
+func (f *fakeRollout) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/service/web/status"):
+		i := f.polls - 1
+		if i >= len(f.statuses) {
+			i = len(f.statuses) - 1
+		}
+		_ = json.NewEncoder(w).Encode(f.statuses[i])
+	case strings.HasSuffix(r.URL.Path, "/status"):
+		f.polls++
+		_ = json.NewEncoder(w).Encode(cluster.LeaseStatus{Services: []*cluster.ServiceStatus{{Name: "web"}}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestWaitForRollout(t *testing.T) {
+	defer func(d time.Duration) { rolloutPollInterval = d }(rolloutPollInterval)
+	rolloutPollInterval = 10 * time.Millisecond
+
+	before := map[string]*cluster.ServiceStatus{"web": {ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}}
+	tests := []struct {
+		name     string
+		statuses []*cluster.ServiceStatus
+		polls    int
+		err      string
+	}{
+		{
+			name: "rolled out",
+			statuses: []*cluster.ServiceStatus{
+				{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+				{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 1, AvailableReplicas: 1},
+				{ObservedGeneration: 2, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+			},
+			polls: 3,
+		},
+		{
+			name: "never rolled out",
+			statuses: []*cluster.ServiceStatus{
+				{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+			},
+			err: "timed out",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &fakeRollout{statuses: tt.statuses}
+			srv := httptest.NewServer(f)
+			defer srv.Close()
+
+			rollouts := []*leaseRollout{{lid: testLease(1, testAddress("provider1")), host: srv.URL, before: before}}
+			err := waitForRollout(context.Background(), gateway.NewClient(), rollouts, map[string]bool{"web": true}, 200*time.Millisecond)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			if f.polls != tt.polls {
+				t.Errorf("expected the rollout to finish after %d polls, got %d", tt.polls, f.polls)
+			}
+		})
+	}
+}
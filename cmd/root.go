@@ -27,6 +27,9 @@ var (
 	// cfgPath is set in initConfig and contains the path of the configuration file
 	cfgPath string
 
+	// cfgErr is set in initConfig when a lenient command is run with a config file that doesn't parse
+	cfgErr error
+
 	// homePath is set by the --home flag and contains the home directory
 	homePath string
 
@@ -52,18 +55,41 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
-	rootCmd.PersistentPreRunE = func(_ *cobra.Command, _ []string) error {
-		// reads `homeDir/config.yaml` into `var config *Config` before each command
-		return initConfig(rootCmd)
-	}
-
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
 	}
 }
 
+// annotationLenient marks commands, and their subcommands, that should still
+// run when the selected network fails validation, e.g. to fix the config
+const annotationLenient = "lenient-config"
+
+// isLenient returns true if the command or one of its parents is annotated with annotationLenient
+func isLenient(cmd *cobra.Command) bool {
+	for ; cmd != nil; cmd = cmd.Parent() {
+		if _, ok := cmd.Annotations[annotationLenient]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// annotationNewNetwork marks commands that create the network selected with
+// --network, so it doesn't have to exist in the config file yet
+const annotationNewNetwork = "new-network"
+
+// createsNetwork returns true if the command is annotated with annotationNewNetwork
+func createsNetwork(cmd *cobra.Command) bool {
+	_, ok := cmd.Annotations[annotationNewNetwork]
+	return ok
+}
+
 func init() {
 	// 	cobra.OnInitialize(initConfig)
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, _ []string) error {
+		// reads `homeDir/config.yaml` into `var config *Config` before each command
+		return initConfig(rootCmd, isLenient(cmd), createsNetwork(cmd))
+	}
 	cobra.EnableCommandSorting = false
 
 	rootCmd.SilenceUsage = true
@@ -71,6 +97,7 @@ func init() {
 	// Register top level flags --home and --debug
 	rootCmd.PersistentFlags().StringVar(&homePath, flags.FlagHome, defaultHome, "set home directory")
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "debug output")
+	rootCmd.PersistentFlags().StringP(flagNetwork, "n", "", "network from the config file to use, defaults to the config's default-network")
 	if err := viper.BindPFlag(flags.FlagHome, rootCmd.Flags().Lookup(flags.FlagHome)); err != nil {
 		panic(err)
 	}
@@ -0,0 +1,415 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ovrclk/akash/events"
+	"github.com/ovrclk/akash/pubsub"
+	"github.com/tendermint/tendermint/libs/bytes"
+	rpcclient "github.com/tendermint/tendermint/rpc/client"
+	rpchttp "github.com/tendermint/tendermint/rpc/client/http"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+var (
+	// rpcHealthInterval is how often the endpoint serving the event subscription is health checked
+	rpcHealthInterval = 10 * time.Second
+)
+
+// rpcPool tracks the RPC endpoints of a network and which one is currently in use
+type rpcPool struct {
+	chainID   string
+	endpoints []string
+
+	mu       sync.Mutex
+	current  string
+	selected bool
+	clients  map[string]*rpchttp.HTTP
+}
+
+// endpointHealth is the result of health checking a single endpoint
+type endpointHealth struct {
+	addr    string
+	height  int64
+	latency time.Duration
+	err     error
+}
+
+func newRPCPool(chainID string, endpoints []string) *rpcPool {
+	return &rpcPool{
+		chainID:   chainID,
+		endpoints: endpoints,
+		clients:   make(map[string]*rpchttp.HTTP),
+	}
+}
+
+// Endpoints returns the network's RPC endpoints, RPCAddr first
+func (n Network) Endpoints() []string {
+	out := []string{n.RPCAddr}
+	for _, addr := range n.RPCAddrs {
+		if addr != n.RPCAddr {
+			out = append(out, addr)
+		}
+	}
+	return out
+}
+
+// rpcPool returns the endpoint pool for the selected network, creating it if needed
+func (c *Config) rpcPool() *rpcPool {
+	c.rpcMu.Lock()
+	defer c.rpcMu.Unlock()
+	if c.rpc == nil {
+		c.rpc = newRPCPool(c.ChainID, c.Endpoints())
+	}
+	return c.rpc
+}
+
+// NewTMClient returns a tendermint RPC client for the selected network which
+// fails over to the next healthy endpoint when a request can't reach the node
+// NOTE: there shouldn't be errors here because we already check the endpoints
+// in validateConfig
+func (c *Config) NewTMClient() rpcclient.Client {
+	pool := c.rpcPool()
+	_, client := pool.client()
+	return &failoverClient{HTTP: client, pool: pool}
+}
+
+// client returns the current endpoint and its client, picking the best endpoint on first use
+func (p *rpcPool) client() (string, *rpchttp.HTTP) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.selected {
+		p.selected = true
+		p.current = p.endpoints[0]
+		if len(p.endpoints) > 1 {
+			best, err := p.best(nil)
+			if err != nil {
+				logger.Error("no healthy rpc endpoint, using the first one", "addr", p.current, "err", err)
+			} else {
+				p.current = best
+			}
+		}
+	}
+	return p.current, p.httpClient(p.current)
+}
+
+// httpClient returns the cached client for the endpoint, must be called with p.mu held
+func (p *rpcPool) httpClient(addr string) *rpchttp.HTTP {
+	if cl, ok := p.clients[addr]; ok {
+		return cl
+	}
+	cl, _ := rpchttp.New(addr, "/websocket")
+	p.clients[addr] = cl
+	return cl
+}
+
+// failover switches away from the failed endpoint to the best healthy endpoint
+// not in tried, returning false if there isn't one
+func (p *rpcPool) failover(failed string, tried map[string]bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// another request already moved off the failed endpoint
+	if p.current != failed && !tried[p.current] {
+		return true
+	}
+
+	skip := map[string]bool{failed: true}
+	for addr := range tried {
+		skip[addr] = true
+	}
+	best, err := p.best(skip)
+	if err != nil {
+		logger.Error("no healthy rpc endpoint to fail over to", "addr", failed, "err", err)
+		return false
+	}
+	logger.Info("switching rpc endpoint", "from", failed, "to", best)
+	p.current = best
+	metricRPCReconnects.Inc()
+	return true
+}
+
+// best health checks the endpoints not in skip concurrently and returns the
+// healthy one with the highest block, preferring lower latency on ties
+func (p *rpcPool) best(skip map[string]bool) (string, error) {
+	var (
+		wg      sync.WaitGroup
+		results = make(chan endpointHealth, len(p.endpoints))
+	)
+	for _, addr := range p.endpoints {
+		if skip[addr] {
+			continue
+		}
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			results <- checkEndpoint(addr, p.chainID)
+		}(addr)
+	}
+	wg.Wait()
+	close(results)
+
+	var (
+		best *endpointHealth
+		errs []string
+	)
+	for res := range results {
+		res := res
+		if res.err != nil {
+			logger.Debug("rpc endpoint unhealthy", "addr", res.addr, "err", res.err)
+			errs = append(errs, fmt.Sprintf("%s: %s", res.addr, res.err))
+			continue
+		}
+		if best == nil || res.height > best.height || (res.height == best.height && res.latency < best.latency) {
+			best = &res
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("all endpoints failed health checks %v", errs)
+	}
+	return best.addr, nil
+}
+
+// checkEndpoint health checks a single endpoint: it must respond to status,
+// not be catching up and be on the expected chain
+func checkEndpoint(addr, chainID string) endpointHealth {
+	res := endpointHealth{addr: addr}
+	client, err := rpchttp.New(addr, "/websocket")
+	if err != nil {
+		res.err = err
+		return res
+	}
+	start := time.Now()
+	status, err := client.Status()
+	res.latency = time.Since(start)
+	switch {
+	case err != nil:
+		res.err = err
+	case status.SyncInfo.CatchingUp:
+		res.err = fmt.Errorf("node is catching up")
+	case status.NodeInfo.Network != chainID:
+		res.err = fmt.Errorf("node is on chain %s, expected %s", status.NodeInfo.Network, chainID)
+	default:
+		res.height = status.SyncInfo.LatestBlockHeight
+	}
+	return res
+}
+
+// do runs fn against the current endpoint, failing over and retrying while fn can't reach the node
+func (p *rpcPool) do(fn func(*rpchttp.HTTP) error) error {
+	tried := make(map[string]bool)
+	for {
+		addr, client := p.client()
+		err := fn(client)
+		if err == nil || !isConnectionError(err) {
+			return err
+		}
+		tried[addr] = true
+		if len(p.endpoints) == 1 || !p.failover(addr, tried) {
+			return err
+		}
+	}
+}
+
+// broadcast runs fn against the current endpoint like do, but only retries on another endpoint
+// when the tx couldn't have reached the node. A node that timed out may still have accepted
+// the tx, so resending it could submit it twice. The pool still fails over for later requests
+func (p *rpcPool) broadcast(fn func(*rpchttp.HTTP) error) error {
+	tried := make(map[string]bool)
+	for {
+		addr, client := p.client()
+		err := fn(client)
+		if err == nil || !isConnectionError(err) {
+			return err
+		}
+		tried[addr] = true
+		if len(p.endpoints) == 1 || !p.failover(addr, tried) || !isDialError(err) {
+			return err
+		}
+	}
+}
+
+// isConnectionError returns true if the request never got a response from the node
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// isDialError returns true if the connection to the node couldn't be opened, so nothing was sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// PublishChainEvents publishes chain events to the bus over a websocket
+// subscription, resubscribing on another endpoint if the current one fails
+// or becomes unhealthy. Events emitted while switching may be missed.
+func (c *Config) PublishChainEvents(ctx context.Context, name string, bus pubsub.Bus) error {
+	pool := c.rpcPool()
+	for {
+		addr, _ := pool.client()
+		switched, err := publishFromEndpoint(ctx, pool, addr, name, bus)
+		if err != nil {
+			return err
+		}
+		if !switched {
+			return nil
+		}
+	}
+}
+
+// publishFromEndpoint publishes chain events from a single endpoint until ctx
+// is done, returning true if the pool has switched to another endpoint
+func publishFromEndpoint(ctx context.Context, pool *rpcPool, addr, name string, bus pubsub.Bus) (bool, error) {
+	log := logger.With("events", "chain", "addr", addr)
+	client, err := rpchttp.New(addr, "/websocket")
+	if err != nil {
+		return false, err
+	}
+	if err = client.Start(); err != nil {
+		if len(pool.endpoints) > 1 && pool.failover(addr, nil) {
+			return true, nil
+		}
+		return false, err
+	}
+	defer client.Stop() // nolint: errcheck
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errch := make(chan error, 1)
+	go func() {
+		errch <- events.Publish(ctx, client, name, bus)
+	}()
+
+	tick := time.NewTicker(rpcHealthInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case err := <-errch:
+			if err != nil && ctx.Err() == nil && len(pool.endpoints) > 1 && pool.failover(addr, nil) {
+				log.Info("event subscription failed, resubscribing", "err", err)
+				return true, nil
+			}
+			return false, err
+		case <-tick.C:
+			if len(pool.endpoints) == 1 {
+				continue
+			}
+			if res := checkEndpoint(addr, pool.chainID); res.err != nil {
+				log.Info("rpc endpoint unhealthy", "err", res.err)
+				if pool.failover(addr, nil) {
+					cancel()
+					<-errch
+					return true, nil
+				}
+			}
+		}
+	}
+}
+
+// failoverClient is a tendermint RPC client that sends queries and broadcasts
+// through an rpcPool, broadcasts are only resent elsewhere if they couldn't be
+// sent. Methods it doesn't override, including event subscriptions, go to the
+// endpoint that was current when it was created.
+type failoverClient struct {
+	*rpchttp.HTTP
+	pool *rpcPool
+}
+
+var _ rpcclient.Client = (*failoverClient)(nil)
+
+// ABCIInfo implements rpcclient.Client
+func (f *failoverClient) ABCIInfo() (out *ctypes.ResultABCIInfo, err error) {
+	err = f.pool.do(func(c *rpchttp.HTTP) (err error) { out, err = c.ABCIInfo(); return })
+	return
+}
+
+// ABCIQuery implements rpcclient.Client
+func (f *failoverClient) ABCIQuery(path string, data bytes.HexBytes) (out *ctypes.ResultABCIQuery, err error) {
+	err = f.pool.do(func(c *rpchttp.HTTP) (err error) { out, err = c.ABCIQuery(path, data); return })
+	return
+}
+
+// ABCIQueryWithOptions implements rpcclient.Client
+func (f *failoverClient) ABCIQueryWithOptions(path string, data bytes.HexBytes, opts rpcclient.ABCIQueryOptions) (out *ctypes.ResultABCIQuery, err error) {
+	err = f.pool.do(func(c *rpchttp.HTTP) (err error) { out, err = c.ABCIQueryWithOptions(path, data, opts); return })
+	return
+}
+
+// BroadcastTxCommit implements rpcclient.Client
+func (f *failoverClient) BroadcastTxCommit(tx tmtypes.Tx) (out *ctypes.ResultBroadcastTxCommit, err error) {
+	err = f.pool.broadcast(func(c *rpchttp.HTTP) (err error) { out, err = c.BroadcastTxCommit(tx); return })
+	return
+}
+
+// BroadcastTxAsync implements rpcclient.Client
+func (f *failoverClient) BroadcastTxAsync(tx tmtypes.Tx) (out *ctypes.ResultBroadcastTx, err error) {
+	err = f.pool.broadcast(func(c *rpchttp.HTTP) (err error) { out, err = c.BroadcastTxAsync(tx); return })
+	return
+}
+
+// BroadcastTxSync implements rpcclient.Client
+func (f *failoverClient) BroadcastTxSync(tx tmtypes.Tx) (out *ctypes.ResultBroadcastTx, err error) {
+	err = f.pool.broadcast(func(c *rpchttp.HTTP) (err error) { out, err = c.BroadcastTxSync(tx); return })
+	return
+}
+
+// Block implements rpcclient.Client
+func (f *failoverClient) Block(height *int64) (out *ctypes.ResultBlock, err error) {
+	err = f.pool.do(func(c *rpchttp.HTTP) (err error) { out, err = c.Block(height); return })
+	return
+}
+
+// BlockResults implements rpcclient.Client
+func (f *failoverClient) BlockResults(height *int64) (out *ctypes.ResultBlockResults, err error) {
+	err = f.pool.do(func(c *rpchttp.HTTP) (err error) { out, err = c.BlockResults(height); return })
+	return
+}
+
+// Commit implements rpcclient.Client
+func (f *failoverClient) Commit(height *int64) (out *ctypes.ResultCommit, err error) {
+	err = f.pool.do(func(c *rpchttp.HTTP) (err error) { out, err = c.Commit(height); return })
+	return
+}
+
+// Validators implements rpcclient.Client
+func (f *failoverClient) Validators(height *int64, page, perPage int) (out *ctypes.ResultValidators, err error) {
+	err = f.pool.do(func(c *rpchttp.HTTP) (err error) { out, err = c.Validators(height, page, perPage); return })
+	return
+}
+
+// Tx implements rpcclient.Client
+func (f *failoverClient) Tx(hash []byte, prove bool) (out *ctypes.ResultTx, err error) {
+	err = f.pool.do(func(c *rpchttp.HTTP) (err error) { out, err = c.Tx(hash, prove); return })
+	return
+}
+
+// TxSearch implements rpcclient.Client
+func (f *failoverClient) TxSearch(query string, prove bool, page, perPage int, orderBy string) (out *ctypes.ResultTxSearch, err error) {
+	err = f.pool.do(func(c *rpchttp.HTTP) (err error) { out, err = c.TxSearch(query, prove, page, perPage, orderBy); return })
+	return
+}
+
+// BlockchainInfo implements rpcclient.Client
+func (f *failoverClient) BlockchainInfo(minHeight, maxHeight int64) (out *ctypes.ResultBlockchainInfo, err error) {
+	err = f.pool.do(func(c *rpchttp.HTTP) (err error) { out, err = c.BlockchainInfo(minHeight, maxHeight); return })
+	return
+}
+
+// Status implements rpcclient.Client
+func (f *failoverClient) Status() (out *ctypes.ResultStatus, err error) {
+	err = f.pool.do(func(c *rpchttp.HTTP) (err error) { out, err = c.Status(); return })
+	return
+}
+
+// Health implements rpcclient.Client
+func (f *failoverClient) Health() (out *ctypes.ResultHealth, err error) {
+	err = f.pool.do(func(c *rpchttp.HTTP) (err error) { out, err = c.Health(); return })
+	return
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ovrclk/akash/sdl"
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagBlockTime    = "block-time"
+	flagSampleBlocks = "sample-blocks"
+
+	// costPeriods are the periods the price per block is converted to
+	costPeriods = []struct {
+		name string
+		d    time.Duration
+	}{
+		{"hour", time.Hour},
+		{"day", 24 * time.Hour},
+		{"month", 30 * 24 * time.Hour},
+	}
+)
+
+// sdlCost is the maximum cost of a deployment group, or of the whole deployment
+type sdlCost struct {
+	Group    string               `json:"group,omitempty"`
+	PerBlock sdk.Coins            `json:"per-block"`
+	Per      map[string]sdk.Coins `json:"per"`
+}
+
+// sdlCostReport is the output of the sdl cost command
+type sdlCostReport struct {
+	Groups    []sdlCost `json:"groups"`
+	Total     sdlCost   `json:"total"`
+	BlockTime string    `json:"block-time"`
+	Measured  bool      `json:"measured"`
+	Balance   sdk.Coins `json:"balance,omitempty"`
+	Runway    string    `json:"runway,omitempty"`
+}
+
+func sdlCostCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cost [file]",
+		Short: "estimate the maximum cost of an sdl per hour, day and month, and how long the balance lasts",
+		Long: `estimate the maximum cost of an sdl per hour, day and month, and how long the balance lasts
+
+the pricing in an sdl is the maximum price per block for each instance. it is
+multiplied by the count of each group and converted to time using the average
+block time, measured over the last --sample-blocks blocks unless --block-time is
+set. the runway is how long the balance of the configured key pays for it`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			buf, err := ReadSDLFile(args[0], cmd.Flags())
+			if err != nil {
+				return err
+			}
+			s, err := sdl.Read(buf)
+			if err != nil {
+				return err
+			}
+			groups, err := s.DeploymentGroups()
+			if err != nil {
+				return err
+			}
+
+			report := &sdlCostReport{}
+			blockTime, err := cmd.Flags().GetDuration(flagBlockTime)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed(flagBlockTime) && blockTime <= 0 {
+				return fmt.Errorf("--%s must be positive, got %s", flagBlockTime, blockTime)
+			}
+			if blockTime == 0 {
+				if config.ChainID == "" {
+					return fmt.Errorf("no network to measure the block time of, pass --%s", flagBlockTime)
+				}
+				n, err := cmd.Flags().GetInt64(flagSampleBlocks)
+				if err != nil {
+					return err
+				}
+				if blockTime, err = config.AverageBlockTime(n); err != nil {
+					return fmt.Errorf("measuring block time, pass --%s to skip: %w", flagBlockTime, err)
+				}
+				report.Measured = true
+			}
+			report.BlockTime = blockTime.Round(time.Millisecond).String()
+
+			var total sdk.Coins
+			for _, g := range groups {
+				var price sdk.Coins
+				for _, r := range g.Resources {
+					price = price.Add(r.FullPrice())
+				}
+				total = total.Add(price...)
+				report.Groups = append(report.Groups, newSDLCost(g.Name, price, blockTime))
+			}
+			report.Total = newSDLCost("", total, blockTime)
+
+			// the runway is only known when there's an account to check
+			if addr := config.GetAccAddress(); addr != nil {
+				if bal, err := config.Balance(addr); err == nil {
+					report.Balance = bal.Spendable
+					report.Runway = runway(bal.Spendable, total, blockTime)
+				}
+			}
+			return report.Print()
+		},
+	}
+	cmd.Flags().Duration(flagBlockTime, 0, "average block time to use instead of measuring it, e.g. 6s, unset measures it")
+	cmd.Flags().Int64(flagSampleBlocks, 100, "number of recent blocks to measure the block time over")
+	AddSDLTemplateFlags(cmd.Flags())
+	return cmd
+}
+
+// newSDLCost converts the price per block to each of the cost periods
+func newSDLCost(group string, perBlock sdk.Coins, blockTime time.Duration) sdlCost {
+	c := sdlCost{Group: group, PerBlock: perBlock, Per: make(map[string]sdk.Coins)}
+	for _, p := range costPeriods {
+		blocks := sdk.NewDec(int64(p.d)).QuoInt64(int64(blockTime))
+		var coins sdk.Coins
+		for _, coin := range perBlock {
+			coins = coins.Add(sdk.NewCoin(coin.Denom, coin.Amount.ToDec().Mul(blocks).TruncateInt()))
+		}
+		c.Per[p.name] = coins
+	}
+	return c
+}
+
+// runway returns how long the balance pays the price per block for, limited by the scarcest denom
+func runway(balance, perBlock sdk.Coins, blockTime time.Duration) string {
+	var blocks sdk.Int
+	for i, coin := range perBlock {
+		n := balance.AmountOf(coin.Denom).Quo(coin.Amount)
+		if i == 0 || n.LT(blocks) {
+			blocks = n
+		}
+	}
+	if len(perBlock) == 0 {
+		return ""
+	}
+	if !blocks.IsInt64() || blocks.Int64() > int64(1<<63-1)/int64(blockTime) {
+		return "practically forever"
+	}
+	return formatDuration(time.Duration(blocks.Int64()) * blockTime)
+}
+
+// formatDuration returns the duration in days and hours when it is longer than a day
+func formatDuration(d time.Duration) string {
+	if d < 24*time.Hour {
+		return d.Round(time.Minute).String()
+	}
+	days := d / (24 * time.Hour)
+	return fmt.Sprintf("%dd%dh", days, (d-days*24*time.Hour)/time.Hour)
+}
+
+// formatCoins formats each coin with formatCoin
+func formatCoins(coins sdk.Coins) string {
+	if coins.Empty() {
+		return "0"
+	}
+	out := ""
+	for i, coin := range coins {
+		denom, amount := formatCoin(coin)
+		if i > 0 {
+			out += ", "
+		}
+		out += amount + " " + denom
+	}
+	return out
+}
+
+// Print prints the report in the configured output format
+func (r *sdlCostReport) Print() error {
+	if config.Output == "json" {
+		bz, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(bz))
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprint(w, "GROUP\tPER BLOCK")
+	for _, p := range costPeriods {
+		fmt.Fprintf(w, "\tPER %s", strings.ToUpper(p.name))
+	}
+	fmt.Fprintln(w)
+	for _, c := range append(r.Groups, r.Total) {
+		name := c.Group
+		if name == "" {
+			name = "total"
+		}
+		fmt.Fprintf(w, "%s\t%s", name, formatCoins(c.PerBlock))
+		for _, p := range costPeriods {
+			fmt.Fprintf(w, "\t%s", formatCoins(c.Per[p.name]))
+		}
+		fmt.Fprintln(w)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	source := "given"
+	if r.Measured {
+		source = "measured"
+	}
+	fmt.Printf("\nblock time: %s (%s)\n", r.BlockTime, source)
+	if r.Runway != "" {
+		fmt.Printf("balance: %s, lasts %s at the maximum price\n", formatCoins(r.Balance), r.Runway)
+	}
+	return nil
+}
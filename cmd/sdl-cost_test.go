@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+)
+
+func TestSDLCostBlockTime(t *testing.T) {
+	home, err := ioutil.TempDir("", "deploy-home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	file := filepath.Join(home, "deploy.yaml")
+	if err = ioutil.WriteFile(file, []byte(testSDL), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for blockTime, want := range map[string]string{
+		"0s":  "--block-time must be positive",
+		"-6s": "--block-time must be positive",
+	} {
+		if err = execute("--home", home, "sdl", "cost", file, "--block-time", blockTime); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("--block-time %s: expected error containing %q, got %v", blockTime, want, err)
+		}
+	}
+}
+
+func TestSDLCost(t *testing.T) {
+	perBlock := sdk.NewCoins(sdk.NewInt64Coin("akash", 10))
+	c := newSDLCost("westcoast", perBlock, 6*time.Second)
+	for period, want := range map[string]int64{"hour": 6000, "day": 144000, "month": 4320000} {
+		if got := c.Per[period].AmountOf("akash").Int64(); got != want {
+			t.Errorf("expected %d akash per %s, got %d", want, period, got)
+		}
+	}
+
+	tests := []struct {
+		balance sdk.Coins
+		want    string
+	}{
+		{sdk.NewCoins(sdk.NewInt64Coin("akash", 6000)), "1h0m0s"},
+		{sdk.NewCoins(sdk.NewInt64Coin("akash", 150000)), "1d1h"},
+		{sdk.NewCoins(sdk.NewInt64Coin("akash", 5)), "0s"},
+		{sdk.NewCoins(sdk.NewInt64Coin("uakt", 5000)), "0s"},
+	}
+	for _, tt := range tests {
+		if got := runway(tt.balance, perBlock, 6*time.Second); got != tt.want {
+			t.Errorf("runway(%s) = %s, expected %s", tt.balance, got, tt.want)
+		}
+	}
+}
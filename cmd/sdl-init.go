@@ -0,0 +1,661 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	flagImage       = "image"
+	flagServiceName = "name"
+	flagPort        = "port"
+	flagGlobal      = "global"
+	flagCPU         = "cpu"
+	flagMemory      = "memory"
+	flagStorage     = "storage"
+	flagRegion      = "region"
+	flagPrice       = "price"
+	flagCount       = "count"
+	flagEnv         = "env"
+	flagPlacement   = "placement"
+	flagCompose     = "compose"
+	flagInteractive = "interactive"
+	flagForce       = "force"
+
+	// composeKeys are the docker-compose service keys converted to the sdl, others are ignored with a warning
+	composeKeys = []string{
+		"image", "entrypoint", "command", "environment", "ports", "expose",
+		"depends_on", "links", "deploy", "container_name", "restart",
+	}
+)
+
+func sdlInitCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "init [file]",
+		Short: "write a new sdl file from flags, prompts or a docker-compose file",
+		Long: `write a new sdl file from flags, prompts or a docker-compose file
+
+with --image a single service sdl is built from the flags. with --compose the
+services of a docker-compose file are converted: image, entrypoint and command,
+environment, ports and expose, depends_on, deploy.replicas and resource limits.
+ports published by compose are exposed globally, exposed ports to the services
+depending on them. compose ${VAR} references are kept as sdl template values.
+without either, and on a terminal, the values are prompted for.
+
+the file defaults to deploy.yaml, pass - to print it. it is validated before it
+is written`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file := "deploy.yaml"
+			if len(args) > 0 {
+				file = args[0]
+			}
+			force, err := cmd.Flags().GetBool(flagForce)
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(file); err == nil && file != "-" && !force {
+				return fmt.Errorf("%s already exists, pass --%s to overwrite it", file, flagForce)
+			}
+
+			opts, err := sdlInitOptionsFromFlags(cmd)
+			if err != nil {
+				return err
+			}
+			compose, err := cmd.Flags().GetString(flagCompose)
+			if err != nil {
+				return err
+			}
+			interactive, err := cmd.Flags().GetBool(flagInteractive)
+			if err != nil {
+				return err
+			}
+
+			var spec *sdlSpec
+			switch {
+			case compose != "":
+				bz, err := ioutil.ReadFile(compose)
+				if err != nil {
+					return err
+				}
+				if spec, err = sdlFromCompose(bz, opts); err != nil {
+					return fmt.Errorf("%s: %w", compose, err)
+				}
+			case interactive || (opts.image == "" && isTerminal(os.Stdin)):
+				if err = opts.prompt(os.Stdin, os.Stderr); err != nil {
+					return err
+				}
+				fallthrough
+			default:
+				if spec, err = opts.sdl(); err != nil {
+					return err
+				}
+			}
+
+			buf, err := spec.Marshal()
+			if err != nil {
+				return err
+			}
+			if file == "-" {
+				_, err = os.Stdout.Write(buf)
+				return err
+			}
+			if err = ioutil.WriteFile(file, buf, 0644); err != nil {
+				return err
+			}
+			logger.Info("wrote sdl", "file", file, "services", len(spec.Services))
+			return nil
+		},
+	}
+	cmd.Flags().String(flagImage, "", "image of the service")
+	cmd.Flags().String(flagServiceName, "web", "name of the service")
+	cmd.Flags().StringSlice(flagPort, []string{"80"}, "ports to expose as [as:]port[/proto], e.g. 80 or 8080:80/tcp, can be repeated")
+	cmd.Flags().Bool(flagGlobal, true, "expose the ports to the internet, otherwise only to other services")
+	cmd.Flags().String(flagCPU, "0.1", "cpus of each service, e.g. 0.5 or 500m")
+	cmd.Flags().String(flagMemory, "128Mi", "memory of each service")
+	cmd.Flags().String(flagStorage, "512Mi", "storage of each service")
+	cmd.Flags().String(flagRegion, "us-west", "region attribute providers must have, empty for any")
+	cmd.Flags().String(flagPrice, "3920akash", "maximum price per block of each service")
+	cmd.Flags().Uint32(flagCount, 1, "instances of each service")
+	cmd.Flags().StringArray(flagEnv, nil, "environment variable NAME=value of the service, can be repeated")
+	cmd.Flags().String(flagPlacement, "dcloud", "name of the placement profile")
+	cmd.Flags().String(flagCompose, "", "docker-compose file to convert")
+	cmd.Flags().BoolP(flagInteractive, "i", false, "prompt for the values, using the flags as defaults")
+	cmd.Flags().Bool(flagForce, false, "overwrite the file if it exists")
+	return cmd
+}
+
+// sdlSpec is an sdl file, the fields are in the order they are written
+type sdlSpec struct {
+	Version    string                                  `yaml:"version"`
+	Services   map[string]*sdlSpecService              `yaml:"services"`
+	Profiles   sdlSpecProfiles                         `yaml:"profiles"`
+	Deployment map[string]map[string]sdlSpecDeployment `yaml:"deployment"`
+}
+
+type sdlSpecService struct {
+	Image        string              `yaml:"image"`
+	Command      []string            `yaml:"command,omitempty"`
+	Args         []string            `yaml:"args,omitempty"`
+	Env          []string            `yaml:"env,omitempty"`
+	Expose       []*sdlSpecExpose    `yaml:"expose,omitempty"`
+	Dependencies []sdlSpecDependency `yaml:"dependencies,omitempty"`
+}
+
+type sdlSpecExpose struct {
+	Port  uint32            `yaml:"port"`
+	As    uint32            `yaml:"as,omitempty"`
+	Proto string            `yaml:"proto,omitempty"`
+	To    []sdlSpecExposeTo `yaml:"to,omitempty"`
+}
+
+type sdlSpecExposeTo struct {
+	Service string `yaml:"service,omitempty"`
+	Global  bool   `yaml:"global,omitempty"`
+}
+
+type sdlSpecDependency struct {
+	Service string `yaml:"service"`
+}
+
+type sdlSpecProfiles struct {
+	Compute   map[string]sdlSpecCompute   `yaml:"compute"`
+	Placement map[string]sdlSpecPlacement `yaml:"placement"`
+}
+
+type sdlSpecCompute struct {
+	CPU     string `yaml:"cpu"`
+	Memory  string `yaml:"memory"`
+	Storage string `yaml:"storage"`
+}
+
+type sdlSpecPlacement struct {
+	Attributes map[string]string       `yaml:"attributes,omitempty"`
+	Pricing    map[string]sdlSpecPrice `yaml:"pricing"`
+}
+
+type sdlSpecPrice struct {
+	Denom  string `yaml:"denom"`
+	Amount string `yaml:"amount"`
+}
+
+type sdlSpecDeployment struct {
+	Profile string `yaml:"profile"`
+	Count   uint32 `yaml:"count"`
+}
+
+// newSDLSpec returns an sdl with the placement and no services
+func newSDLSpec(opts *sdlInitOptions) *sdlSpec {
+	placement := sdlSpecPlacement{Pricing: make(map[string]sdlSpecPrice)}
+	if opts.region != "" {
+		placement.Attributes = map[string]string{"region": opts.region}
+	}
+	return &sdlSpec{
+		Version:  sdlVersions[0],
+		Services: make(map[string]*sdlSpecService),
+		Profiles: sdlSpecProfiles{
+			Compute:   make(map[string]sdlSpecCompute),
+			Placement: map[string]sdlSpecPlacement{opts.placement: placement},
+		},
+		Deployment: make(map[string]map[string]sdlSpecDeployment),
+	}
+}
+
+// addService adds the service with its own compute profile, priced and deployed in the placement
+func (s *sdlSpec) addService(name string, svc *sdlSpecService, compute sdlSpecCompute, count uint32, opts *sdlInitOptions) {
+	s.Services[name] = svc
+	s.Profiles.Compute[name] = compute
+	s.Profiles.Placement[opts.placement].Pricing[name] = sdlSpecPrice{
+		Denom:  opts.price.Denom,
+		Amount: opts.price.Amount.String(),
+	}
+	s.Deployment[name] = map[string]sdlSpecDeployment{
+		opts.placement: {Profile: name, Count: count},
+	}
+}
+
+// Marshal returns the sdl as yaml, failing if it doesn't pass validation.
+// Template values are given a placeholder for validation
+func (s *sdlSpec) Marshal() ([]byte, error) {
+	buf, err := yaml.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := RenderSDL(buf, func(string) (string, bool) { return "placeholder", true })
+	if err != nil {
+		return nil, err
+	}
+	if problems := lintSDL(rendered); sdlErrors(problems) > 0 {
+		msgs := make([]string, 0, len(problems))
+		for _, p := range problems {
+			if !p.Warning {
+				msgs = append(msgs, p.Format("sdl"))
+			}
+		}
+		return nil, fmt.Errorf("generated sdl is invalid:\n%s", strings.Join(msgs, "\n"))
+	}
+	return buf, nil
+}
+
+// sdlInitOptions are the values a single service sdl is built from
+type sdlInitOptions struct {
+	name      string
+	image     string
+	ports     []string
+	global    bool
+	compute   sdlSpecCompute
+	region    string
+	price     sdk.Coin
+	count     uint32
+	env       []string
+	placement string
+}
+
+func sdlInitOptionsFromFlags(cmd *cobra.Command) (opts *sdlInitOptions, err error) {
+	opts = &sdlInitOptions{}
+	flags := cmd.Flags()
+	for _, f := range []struct {
+		name string
+		out  *string
+	}{
+		{flagServiceName, &opts.name},
+		{flagImage, &opts.image},
+		{flagCPU, &opts.compute.CPU},
+		{flagMemory, &opts.compute.Memory},
+		{flagStorage, &opts.compute.Storage},
+		{flagRegion, &opts.region},
+		{flagPlacement, &opts.placement},
+	} {
+		if *f.out, err = flags.GetString(f.name); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ports, err = flags.GetStringSlice(flagPort); err != nil {
+		return nil, err
+	}
+	if opts.global, err = flags.GetBool(flagGlobal); err != nil {
+		return nil, err
+	}
+	if opts.count, err = flags.GetUint32(flagCount); err != nil {
+		return nil, err
+	}
+	if opts.env, err = flags.GetStringArray(flagEnv); err != nil {
+		return nil, err
+	}
+	price, err := flags.GetString(flagPrice)
+	if err != nil {
+		return nil, err
+	}
+	if opts.price, err = sdk.ParseCoin(price); err != nil {
+		return nil, fmt.Errorf("invalid --%s: %w", flagPrice, err)
+	}
+	return opts, nil
+}
+
+// prompt asks for each value on out, reading the answers from in. The current values are the defaults
+func (opts *sdlInitOptions) prompt(in io.Reader, out io.Writer) error {
+	r := bufio.NewReader(in)
+	ask := func(question, def string) (string, error) {
+		if def != "" {
+			fmt.Fprintf(out, "%s [%s]: ", question, def)
+		} else {
+			fmt.Fprintf(out, "%s: ", question)
+		}
+		line, err := r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", fmt.Errorf("reading answer: %w", err)
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			return line, nil
+		}
+		return def, nil
+	}
+
+	var err error
+	for _, q := range []struct {
+		question string
+		out      *string
+	}{
+		{"service name", &opts.name},
+		{"image", &opts.image},
+	} {
+		if *q.out, err = ask(q.question, *q.out); err != nil {
+			return err
+		}
+	}
+	ports, err := ask("ports to expose, e.g. 80 or 8080:80", strings.Join(opts.ports, ","))
+	if err != nil {
+		return err
+	}
+	opts.ports = nil
+	for _, p := range strings.Split(ports, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			opts.ports = append(opts.ports, p)
+		}
+	}
+	if len(opts.ports) > 0 {
+		global, err := ask("expose to the internet (y/n)", map[bool]string{true: "y", false: "n"}[opts.global])
+		if err != nil {
+			return err
+		}
+		opts.global = strings.HasPrefix(strings.ToLower(global), "y")
+	}
+	for _, q := range []struct {
+		question string
+		out      *string
+	}{
+		{"cpu", &opts.compute.CPU},
+		{"memory", &opts.compute.Memory},
+		{"storage", &opts.compute.Storage},
+		{"region, empty for any", &opts.region},
+	} {
+		if *q.out, err = ask(q.question, *q.out); err != nil {
+			return err
+		}
+	}
+	price, err := ask("maximum price per block", opts.price.String())
+	if err != nil {
+		return err
+	}
+	if opts.price, err = sdk.ParseCoin(price); err != nil {
+		return fmt.Errorf("invalid price: %w", err)
+	}
+	return nil
+}
+
+// sdl returns the single service sdl of the options
+func (opts *sdlInitOptions) sdl() (*sdlSpec, error) {
+	if opts.image == "" {
+		return nil, fmt.Errorf("--%s is required, or pass --%s or --%s", flagImage, flagCompose, flagInteractive)
+	}
+	svc := &sdlSpecService{Image: opts.image, Env: opts.env}
+	for _, p := range opts.ports {
+		expose, err := parsePortSpec(p)
+		if err != nil {
+			return nil, err
+		}
+		if opts.global {
+			expose.To = []sdlSpecExposeTo{{Global: true}}
+		}
+		svc.Expose = append(svc.Expose, expose)
+	}
+	spec := newSDLSpec(opts)
+	spec.addService(opts.name, svc, opts.compute, opts.count, opts)
+	return spec, nil
+}
+
+// parsePortSpec parses a docker style port, [ip:][as:]port[/proto]
+func parsePortSpec(spec string) (*sdlSpecExpose, error) {
+	expose := &sdlSpecExpose{}
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		expose.Proto = strings.ToLower(spec[i+1:])
+		spec = spec[:i]
+	}
+	parts := strings.Split(spec, ":")
+	port, err := parsePort(parts[len(parts)-1])
+	if err != nil {
+		return nil, err
+	}
+	expose.Port = port
+	if len(parts) > 1 && parts[len(parts)-2] != "" {
+		if expose.As, err = parsePort(parts[len(parts)-2]); err != nil {
+			return nil, err
+		}
+		if expose.As == expose.Port {
+			expose.As = 0
+		}
+	}
+	return expose, nil
+}
+
+func parsePort(s string) (uint32, error) {
+	if strings.Contains(s, "-") {
+		return 0, fmt.Errorf("port ranges aren't supported: %s", s)
+	}
+	p, err := strconv.ParseUint(s, 10, 16)
+	if err != nil || p == 0 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return uint32(p), nil
+}
+
+// composeFile is the part of a docker-compose file converted to an sdl
+type composeFile struct {
+	Services map[string]composeService `yaml:"services"`
+}
+
+type composeService struct {
+	Image       string        `yaml:"image"`
+	Entrypoint  interface{}   `yaml:"entrypoint"`
+	Command     interface{}   `yaml:"command"`
+	Environment interface{}   `yaml:"environment"`
+	Ports       []interface{} `yaml:"ports"`
+	Expose      []interface{} `yaml:"expose"`
+	DependsOn   interface{}   `yaml:"depends_on"`
+	Links       []string      `yaml:"links"`
+	Deploy      struct {
+		Replicas  *uint32 `yaml:"replicas"`
+		Resources struct {
+			Limits struct {
+				CPUs   string `yaml:"cpus"`
+				Memory string `yaml:"memory"`
+			} `yaml:"limits"`
+		} `yaml:"resources"`
+	} `yaml:"deploy"`
+}
+
+// sdlFromCompose converts the services of a docker-compose file, using opts for what compose doesn't set
+func sdlFromCompose(bz []byte, opts *sdlInitOptions) (*sdlSpec, error) {
+	var raw struct {
+		Services map[string]map[string]interface{} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal(bz, &raw); err != nil {
+		return nil, err
+	}
+	var file composeFile
+	if err := yaml.Unmarshal(bz, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Services) == 0 {
+		return nil, fmt.Errorf("no services, only compose files with a services key are supported")
+	}
+
+	names := make([]string, 0, len(file.Services))
+	for name := range file.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// compose service names may have characters sdl names can't
+	sdlNames := make(map[string]string, len(names))
+	for _, name := range names {
+		sdlNames[name] = strings.ReplaceAll(strings.ToLower(name), "_", "-")
+	}
+
+	// services only reach the exposed ports of the services they depend on
+	dependents := make(map[string][]string)
+	for _, name := range names {
+		for _, dep := range composeDependencies(file.Services[name]) {
+			dependents[dep] = append(dependents[dep], sdlNames[name])
+		}
+	}
+
+	spec := newSDLSpec(opts)
+	for _, name := range names {
+		cs := file.Services[name]
+		log := logger.With("action", "convert-compose", "service", name)
+		for key := range raw.Services[name] {
+			if !contains(composeKeys, key) {
+				log.Info("ignoring unsupported compose key", "key", key)
+			}
+		}
+		if cs.Image == "" {
+			return nil, fmt.Errorf("service %s has no image, build and push it first and set image", name)
+		}
+
+		svc := &sdlSpecService{Image: cs.Image}
+		var err error
+		if svc.Command, err = composeStrings(cs.Entrypoint); err != nil {
+			return nil, fmt.Errorf("service %s entrypoint: %w", name, err)
+		}
+		if svc.Args, err = composeStrings(cs.Command); err != nil {
+			return nil, fmt.Errorf("service %s command: %w", name, err)
+		}
+		if svc.Env, err = composeEnv(cs.Environment); err != nil {
+			return nil, fmt.Errorf("service %s environment: %w", name, err)
+		}
+
+		for _, p := range cs.Ports {
+			expose, err := composePort(p)
+			if err != nil {
+				return nil, fmt.Errorf("service %s ports: %w", name, err)
+			}
+			expose.To = []sdlSpecExposeTo{{Global: true}}
+			svc.Expose = append(svc.Expose, expose)
+		}
+		for _, p := range cs.Expose {
+			expose, err := composePort(p)
+			if err != nil {
+				return nil, fmt.Errorf("service %s expose: %w", name, err)
+			}
+			expose.As = 0
+			for _, dep := range dependents[name] {
+				expose.To = append(expose.To, sdlSpecExposeTo{Service: dep})
+			}
+			if len(expose.To) == 0 {
+				log.Info("exposed port has no dependent service, it won't be reachable", "port", expose.Port)
+			}
+			svc.Expose = append(svc.Expose, expose)
+		}
+		for _, dep := range composeDependencies(cs) {
+			svc.Dependencies = append(svc.Dependencies, sdlSpecDependency{Service: sdlNames[dep]})
+		}
+
+		compute := opts.compute
+		if cs.Deploy.Resources.Limits.CPUs != "" {
+			compute.CPU = cs.Deploy.Resources.Limits.CPUs
+		}
+		if cs.Deploy.Resources.Limits.Memory != "" {
+			compute.Memory = composeBytes(cs.Deploy.Resources.Limits.Memory)
+		}
+		count := opts.count
+		if cs.Deploy.Replicas != nil {
+			count = *cs.Deploy.Replicas
+		}
+		spec.addService(sdlNames[name], svc, compute, count, opts)
+	}
+	return spec, nil
+}
+
+// composeDependencies returns the services from depends_on and links
+func composeDependencies(cs composeService) (deps []string) {
+	switch v := cs.DependsOn.(type) {
+	case []interface{}:
+		for _, d := range v {
+			deps = append(deps, fmt.Sprint(d))
+		}
+	case map[interface{}]interface{}:
+		for d := range v {
+			deps = append(deps, fmt.Sprint(d))
+		}
+	}
+	for _, l := range cs.Links {
+		dep := strings.SplitN(l, ":", 2)[0]
+		if !contains(deps, dep) {
+			deps = append(deps, dep)
+		}
+	}
+	sort.Strings(deps)
+	return deps
+}
+
+// composeStrings converts a compose string or list. Strings are split on spaces, without shell quoting
+func composeStrings(v interface{}) ([]string, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.Fields(val), nil
+	case []interface{}:
+		out := make([]string, 0, len(val))
+		for _, item := range val {
+			out = append(out, fmt.Sprint(item))
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("expected a string or list, got %v", v)
+	}
+}
+
+// composeEnv converts a compose environment list or map. Variables without a
+// value are taken from the environment in compose, they become template values
+func composeEnv(v interface{}) ([]string, error) {
+	var env []string
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case []interface{}:
+		for _, item := range val {
+			kv := fmt.Sprint(item)
+			if !strings.Contains(kv, "=") {
+				kv = fmt.Sprintf("%s=${%s}", kv, kv)
+			}
+			env = append(env, kv)
+		}
+	case map[interface{}]interface{}:
+		for k, item := range val {
+			if item == nil {
+				env = append(env, fmt.Sprintf("%v=${%v}", k, k))
+			} else {
+				env = append(env, fmt.Sprintf("%v=%v", k, item))
+			}
+		}
+		sort.Strings(env)
+	default:
+		return nil, fmt.Errorf("expected a list or map, got %v", v)
+	}
+	return env, nil
+}
+
+// composePort converts a compose port in the short syntax, e.g. "8080:80/udp", or the long syntax
+func composePort(v interface{}) (*sdlSpecExpose, error) {
+	switch val := v.(type) {
+	case int:
+		return parsePortSpec(strconv.Itoa(val))
+	case string:
+		return parsePortSpec(val)
+	case map[interface{}]interface{}:
+		spec := fmt.Sprint(val["target"])
+		if published, ok := val["published"]; ok {
+			spec = fmt.Sprintf("%v:%s", published, spec)
+		}
+		if proto, ok := val["protocol"]; ok {
+			spec = fmt.Sprintf("%s/%v", spec, proto)
+		}
+		return parsePortSpec(spec)
+	default:
+		return nil, fmt.Errorf("unsupported port %v", v)
+	}
+}
+
+// composeBytes converts a docker byte quantity, e.g. 512m or 1gb, to an sdl one.
+// docker suffixes are binary
+func composeBytes(size string) string {
+	s := strings.TrimSuffix(strings.ToLower(size), "b")
+	for suffix, unit := range map[string]string{"k": "Ki", "m": "Mi", "g": "Gi", "t": "Ti"} {
+		if strings.HasSuffix(s, suffix) {
+			return strings.TrimSuffix(s, suffix) + unit
+		}
+	}
+	return s
+}
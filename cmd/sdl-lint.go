@@ -0,0 +1,711 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ovrclk/akash/sdl"
+	"github.com/tendermint/tendermint/libs/bech32"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	// sdlVersions are the sdl versions understood by the akash sdl package
+	sdlVersions = []string{"1.5", "1.5.0"}
+
+	// sdlByteSuffixes are the unit suffixes accepted for memory and storage
+	sdlByteSuffixes = []string{"Ki", "Mi", "Gi", "Ti", "Pi", "Ei", "k", "M", "G", "T", "P", "E"}
+
+	// serviceNameRe matches the service names providers can deploy, they are used as kubernetes names
+	serviceNameRe = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+
+	// yamlLineRe extracts the line number from yaml parse errors
+	yamlLineRe = regexp.MustCompile(`line (\d+)`)
+)
+
+// sdlProblem is an error or warning found in an sdl file
+type sdlProblem struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+	Warning bool   `json:"warning,omitempty"`
+}
+
+// Format returns the problem as a compiler style file:line:col message
+func (p sdlProblem) Format(file string) string {
+	level := "error"
+	if p.Warning {
+		level = "warning"
+	}
+	pos := fmt.Sprintf("%s:%d:%d", file, p.Line, p.Column)
+	if p.Column == 0 {
+		pos = fmt.Sprintf("%s:%d", file, p.Line)
+	}
+	msg := p.Message
+	if p.Path != "" {
+		msg = p.Path + ": " + msg
+	}
+	if p.Hint != "" {
+		msg += " (" + p.Hint + ")"
+	}
+	return fmt.Sprintf("%s: %s: %s", pos, level, msg)
+}
+
+// sdlLinter walks the yaml nodes of an sdl file collecting problems with their positions
+type sdlLinter struct {
+	root     *yaml.Node
+	problems []sdlProblem
+
+	services  map[string]*yaml.Node
+	compute   map[string]*yaml.Node
+	placement map[string]*yaml.Node
+	pricing   map[string]map[string]bool
+	deployed  map[string]bool
+	used      map[string]bool
+}
+
+// lintSDL checks the sdl for problems the akash sdl package either doesn't
+// catch, e.g. misspelt keys that are silently ignored, or reports without a
+// position. When it finds no errors the sdl is also read by the sdl package
+// so that its own validation, e.g. resource limits, is reported too
+func lintSDL(buf []byte) []sdlProblem {
+	l := &sdlLinter{
+		services:  make(map[string]*yaml.Node),
+		compute:   make(map[string]*yaml.Node),
+		placement: make(map[string]*yaml.Node),
+		pricing:   make(map[string]map[string]bool),
+		deployed:  make(map[string]bool),
+		used:      make(map[string]bool),
+	}
+
+	doc := &yaml.Node{}
+	if err := yaml.Unmarshal(buf, doc); err != nil {
+		line, msg := 1, strings.TrimPrefix(err.Error(), "yaml: ")
+		if m := yamlLineRe.FindStringSubmatch(msg); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			msg = strings.TrimPrefix(msg, m[0]+": ")
+		}
+		return []sdlProblem{{Line: line, Message: msg}}
+	}
+	if len(doc.Content) == 0 {
+		return []sdlProblem{{Line: 1, Message: "empty sdl"}}
+	}
+	l.root = doc.Content[0]
+	l.lintRoot()
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		a, b := l.problems[i], l.problems[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	if sdlErrors(l.problems) > 0 {
+		return l.problems
+	}
+	if err := readSDL(buf); err != nil {
+		l.problems = append(l.problems, l.locate(err))
+	}
+	return l.problems
+}
+
+// sdlErrors returns the number of problems that aren't warnings
+func sdlErrors(problems []sdlProblem) (n int) {
+	for _, p := range problems {
+		if !p.Warning {
+			n++
+		}
+	}
+	return n
+}
+
+// readSDL runs the checks done by NewDeploymentData
+func readSDL(buf []byte) error {
+	s, err := sdl.Read(buf)
+	if err != nil {
+		return err
+	}
+	if _, err = s.DeploymentGroups(); err != nil {
+		return err
+	}
+	_, err = s.Manifest()
+	return err
+}
+
+// locate places an error from the sdl package on the placement group it names, or the top of the file
+func (l *sdlLinter) locate(err error) sdlProblem {
+	for name, n := range l.placement {
+		if strings.Contains(err.Error(), "group "+name+":") {
+			return sdlProblem{Line: n.Line, Column: n.Column, Path: "profiles.placement." + name, Message: err.Error()}
+		}
+	}
+	return sdlProblem{Line: l.root.Line, Column: l.root.Column, Message: err.Error()}
+}
+
+func (l *sdlLinter) errorf(n *yaml.Node, path, format string, args ...interface{}) {
+	l.problems = append(l.problems, sdlProblem{Line: n.Line, Column: n.Column, Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *sdlLinter) hintf(n *yaml.Node, path, hint, format string, args ...interface{}) {
+	l.problems = append(l.problems, sdlProblem{Line: n.Line, Column: n.Column, Path: path, Message: fmt.Sprintf(format, args...), Hint: hint})
+}
+
+func (l *sdlLinter) warnf(n *yaml.Node, path, format string, args ...interface{}) {
+	l.problems = append(l.problems, sdlProblem{Line: n.Line, Column: n.Column, Path: path, Message: fmt.Sprintf(format, args...), Warning: true})
+}
+
+// yamlPair is a key and its value in a yaml mapping
+type yamlPair struct {
+	key, val *yaml.Node
+}
+
+// mapping returns the pairs of a mapping node, reporting duplicate keys and
+// keys that aren't allowed. Any keys are allowed when allowed is nil
+func (l *sdlLinter) mapping(n *yaml.Node, path string, allowed ...string) []yamlPair {
+	if n.Kind != yaml.MappingNode {
+		l.errorf(n, path, "expected a mapping, got %s", kindName(n))
+		return nil
+	}
+	var (
+		pairs []yamlPair
+		seen  = make(map[string]*yaml.Node)
+	)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if first, ok := seen[k.Value]; ok {
+			l.errorf(k, join(path, k.Value), "duplicate key, first defined on line %d", first.Line)
+			continue
+		}
+		seen[k.Value] = k
+		if allowed != nil && !contains(allowed, k.Value) {
+			hint := fmt.Sprintf("expected one of %s", strings.Join(allowed, ", "))
+			if s := suggest(k.Value, allowed); s != "" {
+				hint = fmt.Sprintf("did you mean %q?", s)
+			}
+			l.hintf(k, join(path, k.Value), hint, "unknown key %q is ignored", k.Value)
+			continue
+		}
+		pairs = append(pairs, yamlPair{k, v})
+	}
+	return pairs
+}
+
+// require reports each key missing from the pairs
+func (l *sdlLinter) require(n *yaml.Node, path string, pairs []yamlPair, keys ...string) {
+	for _, key := range keys {
+		if lookup(pairs, key) == nil {
+			l.errorf(n, path, "missing required key %q", key)
+		}
+	}
+}
+
+// sequence returns the items of a sequence node
+func (l *sdlLinter) sequence(n *yaml.Node, path string) []*yaml.Node {
+	if n.Kind != yaml.SequenceNode {
+		l.errorf(n, path, "expected a list, got %s", kindName(n))
+		return nil
+	}
+	return n.Content
+}
+
+// scalar returns the value of a scalar node
+func (l *sdlLinter) scalar(n *yaml.Node, path string) (string, bool) {
+	if n.Kind != yaml.ScalarNode {
+		l.errorf(n, path, "expected a value, got %s", kindName(n))
+		return "", false
+	}
+	return n.Value, true
+}
+
+func (l *sdlLinter) lintRoot() {
+	pairs := l.mapping(l.root, "", "version", "include", "services", "profiles", "deployment", "providers")
+	if pairs == nil && l.root.Kind != yaml.MappingNode {
+		return
+	}
+	l.require(l.root, "", pairs, "version", "services", "profiles", "deployment")
+
+	if v := lookup(pairs, "version"); v != nil {
+		if ver, ok := l.scalar(v, "version"); ok && !contains(sdlVersions, strings.TrimPrefix(ver, "v")) {
+			l.hintf(v, "version", `use version: "1.5"`, "unsupported version %q", ver)
+		}
+	}
+
+	// services and profiles are collected first so deployment can refer to them
+	if v := lookup(pairs, "services"); v != nil {
+		services := l.mapping(v, "services")
+		for _, p := range services {
+			l.services[p.key.Value] = p.key
+		}
+		for _, p := range services {
+			l.lintService(p.key, p.val)
+		}
+	}
+	if v := lookup(pairs, "profiles"); v != nil {
+		l.lintProfiles(v)
+	}
+	if v := lookup(pairs, "deployment"); v != nil {
+		l.lintDeployment(v)
+	}
+	if v := lookup(pairs, "providers"); v != nil {
+		l.lintProviders(v)
+	}
+
+	for _, name := range sortedKeys(l.services) {
+		if !l.deployed[name] {
+			l.warnf(l.services[name], "services."+name, "service is not deployed, add it to deployment")
+		}
+	}
+	for _, name := range sortedKeys(l.compute) {
+		if !l.used[name] {
+			l.warnf(l.compute[name], "profiles.compute."+name, "compute profile is not used by any deployment")
+		}
+	}
+}
+
+// lintProviders checks the provider addresses and key=value attributes leases are allowed or denied with
+func (l *sdlLinter) lintProviders(n *yaml.Node) {
+	for _, p := range l.mapping(n, "providers", "allow", "deny") {
+		path := "providers." + p.key.Value
+		for i, item := range l.sequence(p.val, path) {
+			ipath := fmt.Sprintf("%s[%d]", path, i)
+			rule, ok := l.scalar(item, ipath)
+			if !ok {
+				continue
+			}
+			if err := validateProviderRule(rule); err != nil {
+				l.hintf(item, ipath, "use a provider address or key=value", "%s", err)
+				continue
+			}
+			if !strings.Contains(rule, "=") {
+				if _, _, err := bech32.DecodeAndConvert(rule); err != nil {
+					l.hintf(item, ipath, "use a provider address or key=value", "invalid provider address %q: %s", rule, err)
+				}
+			}
+		}
+	}
+}
+
+func (l *sdlLinter) lintService(key, n *yaml.Node) {
+	path := "services." + key.Value
+	if !serviceNameRe.MatchString(key.Value) {
+		l.hintf(key, path, "use lower case letters, digits and -", "invalid service name %q", key.Value)
+	}
+
+	pairs := l.mapping(n, path, "image", "command", "args", "env", "expose", "dependencies")
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	l.require(key, path, pairs, "image")
+	if v := lookup(pairs, "image"); v != nil {
+		if img, ok := l.scalar(v, path+".image"); ok && img == "" {
+			l.errorf(v, path+".image", "image is empty")
+		}
+	}
+	for _, field := range []string{"command", "args"} {
+		if v := lookup(pairs, field); v != nil {
+			for i, item := range l.sequence(v, path+"."+field) {
+				l.scalar(item, fmt.Sprintf("%s.%s[%d]", path, field, i))
+			}
+		}
+	}
+	if v := lookup(pairs, "env"); v != nil {
+		for i, item := range l.sequence(v, path+".env") {
+			ipath := fmt.Sprintf("%s.env[%d]", path, i)
+			if env, ok := l.scalar(item, ipath); ok && !strings.Contains(env, "=") {
+				l.hintf(item, ipath, "use NAME=value", "invalid environment variable %q", env)
+			}
+		}
+	}
+	if v := lookup(pairs, "expose"); v != nil {
+		exposed := make(map[string]int)
+		for i, item := range l.sequence(v, path+".expose") {
+			l.lintExpose(item, fmt.Sprintf("%s.expose[%d]", path, i), key.Value, exposed)
+		}
+	}
+	if v := lookup(pairs, "dependencies"); v != nil {
+		for i, item := range l.sequence(v, path+".dependencies") {
+			ipath := fmt.Sprintf("%s.dependencies[%d]", path, i)
+			dep := l.mapping(item, ipath, "service")
+			if s := lookup(dep, "service"); s != nil {
+				l.serviceRef(s, ipath+".service")
+			}
+		}
+	}
+}
+
+// lintExpose checks an expose rule, exposed tracks the line of each port/proto of the service
+func (l *sdlLinter) lintExpose(n *yaml.Node, path, service string, exposed map[string]int) {
+	pairs := l.mapping(n, path, "port", "as", "proto", "to", "accept")
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	l.require(n, path, pairs, "port")
+
+	port := ""
+	if v := lookup(pairs, "port"); v != nil {
+		if p, ok := l.port(v, path+".port", 1); ok {
+			port = strconv.Itoa(p)
+		}
+	}
+	if v := lookup(pairs, "as"); v != nil {
+		l.port(v, path+".as", 0)
+	}
+	proto := "tcp"
+	if v := lookup(pairs, "proto"); v != nil {
+		if p, ok := l.scalar(v, path+".proto"); ok {
+			switch strings.ToLower(p) {
+			case "tcp", "udp":
+				proto = strings.ToLower(p)
+			default:
+				l.hintf(v, path+".proto", "use tcp or udp", "unknown protocol %q", p)
+			}
+		}
+	}
+	if port != "" {
+		if line, ok := exposed[port+"/"+proto]; ok {
+			l.errorf(n, path, "port %s/%s of service %s is already exposed on line %d", port, proto, service, line)
+		} else {
+			exposed[port+"/"+proto] = n.Line
+		}
+	}
+
+	to := lookup(pairs, "to")
+	if to == nil {
+		l.problems = append(l.problems, sdlProblem{
+			Line:    n.Line,
+			Column:  n.Column,
+			Path:    path,
+			Message: "port is not exposed to anything",
+			Hint:    "add `to: [{global: true}]` or `to: [{service: name}]`",
+			Warning: true,
+		})
+	} else {
+		for i, item := range l.sequence(to, path+".to") {
+			ipath := fmt.Sprintf("%s.to[%d]", path, i)
+			tpairs := l.mapping(item, ipath, "service", "global")
+			s, g := lookup(tpairs, "service"), lookup(tpairs, "global")
+			if s != nil {
+				l.serviceRef(s, ipath+".service")
+			}
+			if g != nil {
+				if v, ok := l.scalar(g, ipath+".global"); ok {
+					if _, err := strconv.ParseBool(v); err != nil {
+						l.errorf(g, ipath+".global", "expected true or false, got %q", v)
+					}
+				}
+			}
+			if s == nil && g == nil && item.Kind == yaml.MappingNode {
+				l.hintf(item, ipath, "set service or global", "expose target is empty")
+			}
+		}
+	}
+
+	if v := lookup(pairs, "accept"); v != nil {
+		for i, item := range l.sequence(v, path+".accept") {
+			ipath := fmt.Sprintf("%s.accept[%d]", path, i)
+			if host, ok := l.scalar(item, ipath); ok {
+				if _, err := url.ParseRequestURI("http://" + host); err != nil {
+					l.errorf(item, ipath, "invalid hostname %q", host)
+				}
+			}
+		}
+	}
+}
+
+// port parses a port number no less than min
+func (l *sdlLinter) port(n *yaml.Node, path string, min int) (int, bool) {
+	v, ok := l.scalar(n, path)
+	if !ok {
+		return 0, false
+	}
+	p, err := strconv.Atoi(v)
+	if err != nil || p < min || p > 65535 {
+		l.errorf(n, path, "invalid port %q, expected %d-65535", v, min)
+		return 0, false
+	}
+	return p, true
+}
+
+// serviceRef reports a reference to a service that isn't defined
+func (l *sdlLinter) serviceRef(n *yaml.Node, path string) {
+	if name, ok := l.scalar(n, path); ok {
+		if _, ok := l.services[name]; !ok {
+			l.unknownRef(n, path, "service", name, sortedKeys(l.services))
+		}
+	}
+}
+
+// unknownRef reports a reference to something that isn't defined, suggesting the closest name
+func (l *sdlLinter) unknownRef(n *yaml.Node, path, kind, name string, known []string) {
+	hint := ""
+	if s := suggest(name, known); s != "" {
+		hint = fmt.Sprintf("did you mean %q?", s)
+	} else if len(known) > 0 {
+		hint = fmt.Sprintf("expected one of %s", strings.Join(known, ", "))
+	}
+	l.hintf(n, path, hint, "unknown %s %q", kind, name)
+}
+
+func (l *sdlLinter) lintProfiles(n *yaml.Node) {
+	pairs := l.mapping(n, "profiles", "compute", "placement")
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	l.require(n, "profiles", pairs, "compute", "placement")
+
+	if v := lookup(pairs, "compute"); v != nil {
+		for _, p := range l.mapping(v, "profiles.compute") {
+			l.compute[p.key.Value] = p.key
+			l.lintCompute(p.val, "profiles.compute."+p.key.Value)
+		}
+	}
+	if v := lookup(pairs, "placement"); v != nil {
+		for _, p := range l.mapping(v, "profiles.placement") {
+			l.placement[p.key.Value] = p.key
+			l.lintPlacement(p.key.Value, p.val)
+		}
+	}
+}
+
+func (l *sdlLinter) lintCompute(n *yaml.Node, path string) {
+	pairs := l.mapping(n, path, "cpu", "memory", "storage")
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	l.require(n, path, pairs, "cpu", "memory", "storage")
+
+	if v := lookup(pairs, "cpu"); v != nil {
+		if cpu, ok := l.scalar(v, path+".cpu"); ok {
+			var err error
+			if strings.HasSuffix(cpu, "m") {
+				_, err = strconv.ParseUint(strings.TrimSuffix(cpu, "m"), 10, 32)
+			} else {
+				var f float64
+				if f, err = strconv.ParseFloat(cpu, 64); err == nil && f <= 0 {
+					err = fmt.Errorf("not positive")
+				}
+			}
+			if err != nil {
+				l.hintf(v, path+".cpu", "use cpus, e.g. 0.5, or millicpus, e.g. 500m", "invalid cpu %q", cpu)
+			}
+		}
+	}
+	for _, field := range []string{"memory", "storage"} {
+		v := lookup(pairs, field)
+		if v == nil {
+			continue
+		}
+		if size, ok := l.scalar(v, path+"."+field); ok && !validByteQuantity(size) {
+			l.hintf(v, path+"."+field, "use bytes with an optional suffix, e.g. 512Mi or 1Gi", "invalid %s %q", field, size)
+		}
+	}
+}
+
+// validByteQuantity returns true if the size parses as an sdl memory or storage quantity
+func validByteQuantity(size string) bool {
+	for _, suffix := range sdlByteSuffixes {
+		if strings.HasSuffix(size, suffix) {
+			size = strings.TrimSuffix(size, suffix)
+			break
+		}
+	}
+	f, err := strconv.ParseFloat(size, 64)
+	return err == nil && f > 0
+}
+
+func (l *sdlLinter) lintPlacement(name string, n *yaml.Node) {
+	path := "profiles.placement." + name
+	pairs := l.mapping(n, path, "attributes", "pricing")
+	if n.Kind != yaml.MappingNode {
+		return
+	}
+	l.require(n, path, pairs, "pricing")
+
+	if v := lookup(pairs, "attributes"); v != nil {
+		for _, p := range l.mapping(v, path+".attributes") {
+			l.scalar(p.val, path+".attributes."+p.key.Value)
+		}
+	}
+
+	l.pricing[name] = make(map[string]bool)
+	v := lookup(pairs, "pricing")
+	if v == nil {
+		return
+	}
+	denom := ""
+	for _, p := range l.mapping(v, path+".pricing") {
+		ppath := path + ".pricing." + p.key.Value
+		l.pricing[name][p.key.Value] = true
+		if _, ok := l.compute[p.key.Value]; !ok {
+			l.unknownRef(p.key, ppath, "compute profile", p.key.Value, sortedKeys(l.compute))
+		}
+
+		price := l.mapping(p.val, ppath, "denom", "amount")
+		if p.val.Kind != yaml.MappingNode {
+			continue
+		}
+		l.require(p.val, ppath, price, "denom", "amount")
+		if d := lookup(price, "denom"); d != nil {
+			if val, ok := l.scalar(d, ppath+".denom"); ok {
+				switch {
+				case sdk.ValidateDenom(val) != nil:
+					l.hintf(d, ppath+".denom", "denoms are 3-16 lower case letters and digits", "invalid denom %q", val)
+				case denom != "" && val != denom:
+					l.hintf(d, ppath+".denom", "price every profile of a placement in the same denom", "denom %q differs from %q", val, denom)
+				default:
+					denom = val
+				}
+			}
+		}
+		if a := lookup(price, "amount"); a != nil {
+			if val, ok := l.scalar(a, ppath+".amount"); ok {
+				if amt, ok := sdk.NewIntFromString(val); !ok || !amt.IsPositive() {
+					l.hintf(a, ppath+".amount", "use a whole number of the denom", "invalid amount %q", val)
+				}
+			}
+		}
+	}
+}
+
+func (l *sdlLinter) lintDeployment(n *yaml.Node) {
+	for _, svc := range l.mapping(n, "deployment") {
+		path := "deployment." + svc.key.Value
+		if _, ok := l.services[svc.key.Value]; !ok {
+			l.unknownRef(svc.key, path, "service", svc.key.Value, sortedKeys(l.services))
+		}
+		l.deployed[svc.key.Value] = true
+
+		for _, pl := range l.mapping(svc.val, path) {
+			ppath := path + "." + pl.key.Value
+			if _, ok := l.placement[pl.key.Value]; !ok {
+				l.unknownRef(pl.key, ppath, "placement profile", pl.key.Value, sortedKeys(l.placement))
+			}
+
+			pairs := l.mapping(pl.val, ppath, "profile", "count")
+			if pl.val.Kind != yaml.MappingNode {
+				continue
+			}
+			l.require(pl.val, ppath, pairs, "profile", "count")
+			if v := lookup(pairs, "profile"); v != nil {
+				if profile, ok := l.scalar(v, ppath+".profile"); ok {
+					l.used[profile] = true
+					if _, ok := l.compute[profile]; !ok {
+						l.unknownRef(v, ppath+".profile", "compute profile", profile, sortedKeys(l.compute))
+					} else if prices, ok := l.pricing[pl.key.Value]; ok && !prices[profile] {
+						l.hintf(v, ppath+".profile", fmt.Sprintf("add it to profiles.placement.%s.pricing", pl.key.Value), "placement %s has no pricing for profile %q", pl.key.Value, profile)
+					}
+				}
+			}
+			if v := lookup(pairs, "count"); v != nil {
+				if count, ok := l.scalar(v, ppath+".count"); ok {
+					if c, err := strconv.ParseUint(count, 10, 32); err != nil || c == 0 {
+						l.errorf(v, ppath+".count", "invalid count %q, expected a whole number of at least 1", count)
+					}
+				}
+			}
+		}
+	}
+}
+
+// lookup returns the value of the key in the pairs, nil if it isn't there
+func lookup(pairs []yamlPair, key string) *yaml.Node {
+	for _, p := range pairs {
+		if p.key.Value == key {
+			return p.val
+		}
+	}
+	return nil
+}
+
+func kindName(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	case yaml.AliasNode:
+		return "an alias"
+	}
+	if n.Tag == "!!null" {
+		return "nothing"
+	}
+	return fmt.Sprintf("%q", n.Value)
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]*yaml.Node) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// suggest returns the candidate closest to s if it is a likely typo
+func suggest(s string, candidates []string) string {
+	best, bestDist := "", 3
+	for _, c := range candidates {
+		if d := editDistance(strings.ToLower(s), c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the number of single character edits, counting
+// swapping two neighbouring characters as one, needed to turn a into b
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func minInt(first int, rest ...int) int {
+	for _, v := range rest {
+		if v < first {
+			first = v
+		}
+	}
+	return first
+}
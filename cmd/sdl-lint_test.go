@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+const testSDL = `version: "1.5"
+
+services:
+  web:
+    image: quay.io/ovrclk/demo-app
+    expose:
+      - port: 80
+        as: 80
+        to:
+          - global: true
+
+profiles:
+  compute:
+    web:
+      cpu: 0.1
+      memory: 16Mi
+      storage: 128Mi
+  placement:
+    westcoast:
+      attributes:
+        region: us-west
+      pricing:
+        web:
+          denom: akash
+          amount: 3920
+
+deployment:
+  web:
+    westcoast:
+      profile: web
+      count: 1
+`
+
+func TestLintSDL(t *testing.T) {
+	type problem struct {
+		line, column int
+		warning      bool
+		path, msg    string
+	}
+	tests := []struct {
+		name string
+		sdl  string
+		want []problem
+	}{
+		{
+			name: "valid",
+			sdl:  testSDL,
+		},
+		{
+			name: "misspelt key",
+			sdl:  strings.Replace(testSDL, "    image:", "    imag:", 1),
+			want: []problem{
+				{4, 3, false, "services.web", `missing required key "image"`},
+				{5, 5, false, "services.web.imag", `unknown key "imag" is ignored`},
+			},
+		},
+		{
+			name: "duplicate key",
+			sdl:  strings.Replace(testSDL, "    image:", "    image: nginx\n    image:", 1),
+			want: []problem{{6, 5, false, "services.web.image", "duplicate key, first defined on line 5"}},
+		},
+		{
+			name: "unknown profile",
+			sdl:  strings.Replace(testSDL, "profile: web", "profile: api", 1),
+			want: []problem{
+				{14, 5, true, "profiles.compute.web", "compute profile is not used by any deployment"},
+				{30, 16, false, "deployment.web.westcoast.profile", `unknown compute profile "api"`},
+			},
+		},
+		{
+			name: "memory unit",
+			sdl:  strings.Replace(testSDL, "16Mi", "16MB", 1),
+			want: []problem{{16, 15, false, "profiles.compute.web.memory", `invalid memory "16MB"`}},
+		},
+		{
+			name: "port out of range",
+			sdl:  strings.Replace(testSDL, "port: 80", "port: 70000", 1),
+			want: []problem{{7, 15, false, "services.web.expose[0].port", `invalid port "70000"`}},
+		},
+		{
+			name: "unsupported version",
+			sdl:  strings.Replace(testSDL, `"1.5"`, `"2.0"`, 1),
+			want: []problem{{1, 10, false, "version", `unsupported version "2.0"`}},
+		},
+		{
+			name: "service not deployed",
+			sdl:  strings.Replace(testSDL, "profiles:", "  db:\n    image: postgres\n\nprofiles:", 1),
+			want: []problem{{12, 3, true, "services.db", "service is not deployed"}},
+		},
+		{
+			name: "invalid providers",
+			sdl:  testSDL + "providers:\n  allow:\n    - region=\n  deny:\n    - akash1bad\n",
+			want: []problem{
+				{34, 7, false, "providers.allow[0]", `invalid provider attribute "region="`},
+				{36, 7, false, "providers.deny[0]", `invalid provider address "akash1bad"`},
+			},
+		},
+		{
+			name: "invalid yaml",
+			sdl:  "version: \"1.5\"\nservices:\n  web: [\n",
+			want: []problem{{3, 0, false, "", "did not find expected node content"}},
+		},
+		{
+			name: "empty",
+			sdl:  "",
+			want: []problem{{1, 0, false, "", "empty sdl"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lintSDL([]byte(tt.sdl))
+			if len(got) != len(tt.want) {
+				for _, p := range got {
+					t.Log(p.Format("sdl"))
+				}
+				t.Fatalf("expected %d problems, got %d", len(tt.want), len(got))
+			}
+			for i, want := range tt.want {
+				p := got[i]
+				if p.Line != want.line || p.Column != want.column || p.Warning != want.warning || p.Path != want.path ||
+					!strings.Contains(p.Message, want.msg) {
+					t.Errorf("expected %d:%d warning=%t %s: %s, got %s", want.line, want.column, want.warning, want.path, want.msg, p.Format("sdl"))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	flagSet    = "set"
+	flagValues = "values"
+
+	// sdlVarRe matches $$ and ${NAME} or ${NAME:-default} in sdl templates
+	sdlVarRe = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_.]*)(:-[^}]*)?\}`)
+)
+
+// AddSDLTemplateFlags adds the flags for the values substituted into sdl templates
+func AddSDLTemplateFlags(flags *pflag.FlagSet) {
+	flags.StringArray(flagSet, nil, "set a template value, e.g. --set image.tag=v2, can be repeated")
+	flags.StringArrayP(flagValues, "f", nil, "yaml file of template values, can be repeated with later files taking precedence")
+}
+
+// sdlTemplateValues returns the lookup for template values. A value is taken
+// from, in order of precedence, --set, the environment and the --values files
+func sdlTemplateValues(flags *pflag.FlagSet) (func(string) (string, bool), error) {
+	values := make(map[string]string)
+	if flags.Lookup(flagValues) != nil {
+		files, err := flags.GetStringArray(flagValues)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			bz, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			tree := make(map[string]interface{})
+			if err = yaml.Unmarshal(bz, &tree); err != nil {
+				return nil, fmt.Errorf("values file %s: %w", file, err)
+			}
+			if err = flattenValues("", tree, values); err != nil {
+				return nil, fmt.Errorf("values file %s: %w", file, err)
+			}
+		}
+	}
+
+	set := make(map[string]string)
+	if flags.Lookup(flagSet) != nil {
+		pairs, err := flags.GetStringArray(flagSet)
+		if err != nil {
+			return nil, err
+		}
+		for _, pair := range pairs {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 || kv[0] == "" {
+				return nil, fmt.Errorf("invalid --%s %q, expected key=value", flagSet, pair)
+			}
+			set[kv[0]] = kv[1]
+		}
+	}
+
+	return func(name string) (string, bool) {
+		if v, ok := set[name]; ok {
+			return v, true
+		}
+		if v, ok := os.LookupEnv(name); ok {
+			return v, true
+		}
+		v, ok := values[name]
+		return v, ok
+	}, nil
+}
+
+// flattenValues adds the scalar values of the tree to values with dotted keys, e.g. image.tag
+func flattenValues(prefix string, tree map[string]interface{}, values map[string]string) error {
+	for k, v := range tree {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch val := v.(type) {
+		case map[interface{}]interface{}:
+			sub := make(map[string]interface{}, len(val))
+			for sk, sv := range val {
+				sub[fmt.Sprint(sk)] = sv
+			}
+			if err := flattenValues(key, sub, values); err != nil {
+				return err
+			}
+		case []interface{}:
+			return fmt.Errorf("%s: lists aren't supported as template values", key)
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(val)
+		}
+	}
+	return nil
+}
+
+// RenderSDL substitutes ${NAME} with its template value, or the default given
+// as ${NAME:-default}. $$ is a literal $. Every value without a default that
+// isn't set is reported with its line
+func RenderSDL(tmpl []byte, lookup func(string) (string, bool)) ([]byte, error) {
+	var (
+		out     bytes.Buffer
+		missing []string
+		last    int
+	)
+	for _, m := range sdlVarRe.FindAllSubmatchIndex(tmpl, -1) {
+		out.Write(tmpl[last:m[0]])
+		last = m[1]
+
+		if string(tmpl[m[0]:m[1]]) == "$$" {
+			out.WriteByte('$')
+			continue
+		}
+		name := string(tmpl[m[2]:m[3]])
+		if v, ok := lookup(name); ok {
+			out.WriteString(v)
+			continue
+		}
+		if m[4] >= 0 {
+			out.Write(tmpl[m[4]+len(":-") : m[5]])
+			continue
+		}
+		line := bytes.Count(tmpl[:m[0]], []byte("\n")) + 1
+		missing = append(missing, fmt.Sprintf("%s (line %d)", name, line))
+	}
+	out.Write(tmpl[last:])
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("template values not set: %s, pass them with --%s, the environment or a --%s file",
+			strings.Join(missing, ", "), flagSet, flagValues)
+	}
+	return out.Bytes(), nil
+}
+
+// ReadSDLFile reads the sdl file and renders it with the template values from the flags
+func ReadSDLFile(file string, flags *pflag.FlagSet) ([]byte, error) {
+	tmpl, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	lookup, err := sdlTemplateValues(flags)
+	if err != nil {
+		return nil, err
+	}
+	return RenderSDL(tmpl, lookup)
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestRenderSDL(t *testing.T) {
+	values := map[string]string{"image.tag": "v2", "empty": "", "port": "8080"}
+	lookup := func(name string) (string, bool) {
+		v, ok := values[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		name string
+		tmpl string
+		want string
+		err  string
+	}{
+		{"no variables", "image: nginx\n", "image: nginx\n", ""},
+		{"value", "image: demo:${image.tag}", "image: demo:v2", ""},
+		{"several values", "${port}:${port}", "8080:8080", ""},
+		{"set empty value", "a${empty}b", "ab", ""},
+		{"default unused", "tag: ${image.tag:-latest}", "tag: v2", ""},
+		{"default", "tag: ${missing:-latest}", "tag: latest", ""},
+		{"empty default", "tag: ${missing:-}", "tag: ", ""},
+		{"escaped dollar", "price: $$5 ${port}", "price: $5 8080", ""},
+		{"plain dollar kept", "cmd: echo $HOME", "cmd: echo $HOME", ""},
+		{"missing with lines", "a: 1\nb: ${x}\nc: ${y.z}\n", "", "x (line 2), y.z (line 3)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RenderSDL([]byte(tt.tmpl), lookup)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("expected error containing %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSDLTemplateValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deploy-values")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	base, override := filepath.Join(dir, "base.yaml"), filepath.Join(dir, "override.yaml")
+	if err = ioutil.WriteFile(base, []byte("image:\n  tag: v1\n  name: demo\nreplicas: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(override, []byte("image:\n  tag: v2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("DEPLOY_TEST_REGION", "us-west")
+	defer os.Unsetenv("DEPLOY_TEST_REGION")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	AddSDLTemplateFlags(flags)
+	if err = flags.Parse([]string{"-f", base, "-f", override, "--set", "replicas=3", "--set", "DEPLOY_TEST_REGION=eu"}); err != nil {
+		t.Fatal(err)
+	}
+	lookup, err := sdlTemplateValues(flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"image.name", "demo", true},
+		{"image.tag", "v2", true},
+		{"replicas", "3", true},
+		{"DEPLOY_TEST_REGION", "eu", true},
+		{"image", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := lookup(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("lookup(%q) = %q, %t, expected %q, %t", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(sdlCmd())
+}
+
+// sdlCmd represents the sdl command
+func sdlCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sdl",
+		Short: "work with sdl files",
+		Annotations: map[string]string{
+			annotationLenient: "true",
+		},
+	}
+	cmd.AddCommand(
+		sdlValidateCmd(),
+		sdlRenderCmd(),
+		sdlCostCmd(),
+		sdlInitCmd(),
+	)
+	return cmd
+}
+
+func sdlValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "validate [file]",
+		Aliases: []string{"lint"},
+		Short:   "check an sdl file for errors, printing the line and column of each",
+		Long: `check an sdl file for errors, printing the line and column of each
+
+the schema version, references between services, profiles and deployment, expose
+rules, cpu/memory/storage units, pricing and duplicate keys are checked. warnings,
+e.g. unused profiles, are printed but don't fail validation. exits non-zero when
+there are errors so it can be used in pre-commit hooks`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			buf, err := ReadSDLFile(args[0], cmd.Flags())
+			if err != nil {
+				return err
+			}
+
+			problems := lintSDL(buf)
+			if config.Output == "json" {
+				if problems == nil {
+					problems = []sdlProblem{}
+				}
+				bz, err := json.MarshalIndent(problems, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(bz))
+			} else {
+				for _, p := range problems {
+					fmt.Println(p.Format(args[0]))
+				}
+			}
+
+			if n := sdlErrors(problems); n > 0 {
+				return fmt.Errorf("%s has %d errors", args[0], n)
+			}
+			if config.Output != "json" {
+				fmt.Printf("%s is valid\n", args[0])
+			}
+			return nil
+		},
+	}
+	AddSDLTemplateFlags(cmd.Flags())
+	return cmd
+}
+
+func sdlRenderCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "render [file]",
+		Short: "print an sdl template with its values substituted, as it would be deployed",
+		Long: `print an sdl template with its values substituted, as it would be deployed
+
+${NAME} is replaced with the value of NAME, taken from, in order of precedence:
+  1. --set NAME=value
+  2. the environment variable NAME
+  3. the --values files, where nested keys are joined with dots, e.g. image.tag
+
+${NAME:-default} falls back to default when NAME isn't set and $$ is a literal $`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			buf, err := ReadSDLFile(args[0], cmd.Flags())
+			if err != nil {
+				return err
+			}
+			fmt.Print(string(buf))
+			return nil
+		},
+	}
+	AddSDLTemplateFlags(cmd.Flags())
+	return cmd
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/x/bank"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(sendCmd())
+}
+
+// sendCmd represents the send command
+func sendCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "send [to] [amount]",
+		Short: "send coins from the configured key to another address, e.g. to top up a deployer account",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := config.SetGasOnConfig(); err != nil {
+				return err
+			}
+
+			from := config.GetAccAddress()
+			if from == nil {
+				return fmt.Errorf("no key loaded, create one with `deploy key-add`")
+			}
+			to, err := sdk.AccAddressFromBech32(args[0])
+			if err != nil {
+				return err
+			}
+			amount, err := sdk.ParseCoins(args[1])
+			if err != nil {
+				return err
+			}
+
+			res, err := config.SendMsgs([]sdk.Msg{bank.NewMsgSend(from, to, amount)})
+			log := logger.With(
+				"hash", res.TxHash,
+				"code", res.Code,
+				"action", "send",
+				"to", to,
+				"amount", amount,
+			)
+			if err != nil {
+				log.Error("tx failed", "log", res.RawLog)
+				return err
+			}
+			log.Info("tx sent successfully")
+			return nil
+		},
+	}
+}
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/x/auth"
+)
+
+// txQueue hands out account sequences locally for a single account and
+// batches messages queued while a transaction is being broadcast into one
+// multi-msg transaction. Signing and broadcasting is serialized so that
+// sequences reach the mempool in order, waiting for block inclusion is not.
+type txQueue struct {
+	addr sdk.AccAddress
+
+	mu      sync.Mutex
+	pending []*txRequest
+	sending bool
+
+	// sequence tracking, only touched by the goroutine draining the queue
+	synced bool
+	accNum uint64
+	seq    uint64
+}
+
+// txSender signs, broadcasts and confirms the transactions of a txQueue, it is implemented by *Config
+type txSender interface {
+	// account returns the account number and sequence of the address on chain
+	account(addr sdk.AccAddress) (accNum, seq uint64, err error)
+	// send signs the messages with the given sequence and broadcasts them
+	send(msgs []sdk.Msg, accNum, seq uint64, scale float64) (sdk.TxResponse, error)
+	// confirm waits for a broadcast transaction and delivers the result to the requests
+	confirm(res sdk.TxResponse, err error, reqs []*txRequest)
+}
+
+// txRequest is a set of messages waiting to be sent along with the channel the result is delivered on
+type txRequest struct {
+	msgs  []sdk.Msg
+	scale float64
+	done  chan txResult
+}
+
+type txResult struct {
+	res sdk.TxResponse
+	err error
+}
+
+// IsSequenceMismatch returns true if the transaction was rejected because it was signed with the wrong account sequence
+func (e *TxError) IsSequenceMismatch() bool {
+	if e.Codespace != sdkerrors.RootCodespace {
+		return false
+	}
+	switch e.Code {
+	case sdkerrors.ErrInvalidSequence.ABCICode():
+		return true
+	case sdkerrors.ErrUnauthorized.ABCICode():
+		return strings.Contains(e.RawLog, "account sequence")
+	}
+	return false
+}
+
+// txQueue returns the transaction queue for the given account, creating it if needed
+func (c *Config) txQueue(addr sdk.AccAddress) *txQueue {
+	c.txqMu.Lock()
+	defer c.txqMu.Unlock()
+	if c.txQueues == nil {
+		c.txQueues = make(map[string]*txQueue)
+	}
+	q, ok := c.txQueues[addr.String()]
+	if !ok {
+		q = &txQueue{addr: addr}
+		c.txQueues[addr.String()] = q
+	}
+	return q
+}
+
+// enqueue adds the messages to the queue and returns the channel their result will be delivered on.
+// The second return value is true if the caller is responsible for draining the queue.
+func (q *txQueue) enqueue(msgs []sdk.Msg, scale float64) (<-chan txResult, bool) {
+	req := &txRequest{msgs: msgs, scale: scale, done: make(chan txResult, 1)}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pending = append(q.pending, req)
+	if q.sending {
+		return req.done, false
+	}
+	q.sending = true
+	return req.done, true
+}
+
+// next returns the queued requests, or nil once the queue is empty and the drain loop should stop
+func (q *txQueue) next() []*txRequest {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.pending) == 0 {
+		q.sending = false
+		return nil
+	}
+	out := q.pending
+	q.pending = nil
+	return out
+}
+
+// sequence returns the account number and next sequence, fetching them from the chain if they aren't known
+func (q *txQueue) sequence(s txSender) (uint64, uint64, error) {
+	if q.synced {
+		return q.accNum, q.seq, nil
+	}
+	accNum, seq, err := s.account(q.addr)
+	if err != nil {
+		return 0, 0, err
+	}
+	q.accNum, q.seq, q.synced = accNum, seq, true
+	return q.accNum, q.seq, nil
+}
+
+// drain signs and broadcasts queued requests until the queue is empty
+func (q *txQueue) drain(s txSender) {
+	for batch := q.next(); batch != nil; batch = q.next() {
+		// a batch pays for the most generous request in it
+		var msgs []sdk.Msg
+		scale := 1.0
+		for _, req := range batch {
+			msgs = append(msgs, req.msgs...)
+			if req.scale > scale {
+				scale = req.scale
+			}
+		}
+		res, err := q.broadcast(s, msgs, scale)
+
+		// if a batch fails, retry each request on its own so one bad message
+		// doesn't fail the others
+		if err != nil && len(batch) > 1 {
+			logger.Debug("batched tx failed, retrying messages individually", "requests", len(batch), "err", err)
+			for _, req := range batch {
+				res, err := q.broadcast(s, req.msgs, req.scale)
+				go s.confirm(res, err, []*txRequest{req})
+			}
+			continue
+		}
+		go s.confirm(res, err, batch)
+	}
+}
+
+// broadcast signs and broadcasts the messages with the next local sequence,
+// re-syncing from the chain and retrying once on a sequence mismatch
+func (q *txQueue) broadcast(s txSender, msgs []sdk.Msg, scale float64) (res sdk.TxResponse, err error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		var accNum, seq uint64
+		if accNum, seq, err = q.sequence(s); err != nil {
+			return res, err
+		}
+
+		if res, err = s.send(msgs, accNum, seq, scale); err == nil {
+			err = NewTxError(res)
+		}
+
+		switch {
+		case err == nil:
+			// passed CheckTx, the sequence is consumed
+			q.seq++
+			return res, nil
+		case isSequenceMismatch(err):
+			logger.Info("account sequence mismatch, re-syncing from chain", "addr", q.addr, "sequence", seq)
+			q.synced = false
+		default:
+			// we can't be sure what the node did with the tx, so re-sync before the next one
+			q.synced = false
+			return res, err
+		}
+	}
+	return res, err
+}
+
+// account fetches the account number and sequence of the address from the chain
+func (c *Config) account(addr sdk.AccAddress) (uint64, uint64, error) {
+	acc, err := auth.NewAccountRetriever(c.CLICtx(c.NewTMClient())).GetAccount(addr)
+	if err != nil {
+		return 0, 0, err
+	}
+	return acc.GetAccountNumber(), acc.GetSequence(), nil
+}
+
+// send signs the messages with the given account sequence and broadcasts them
+func (c *Config) send(msgs []sdk.Msg, accNum, seq uint64, scale float64) (sdk.TxResponse, error) {
+	out, err := c.BuildAndSignTx(msgs, accNum, seq, scale)
+	if err != nil {
+		return sdk.TxResponse{}, err
+	}
+	res, err := c.BroadcastTx(out)
+	observeBroadcast(res, err)
+	return res, err
+}
+
+// confirm waits for a broadcast transaction to be included in a block and delivers the result to the requests
+func (c *Config) confirm(res sdk.TxResponse, err error, reqs []*txRequest) {
+	if err == nil {
+		res, err = c.confirmTx(res)
+		metricGasUsed.Add(float64(res.GasUsed))
+	}
+	for _, req := range reqs {
+		req.done <- txResult{res, err}
+	}
+}
+
+func isSequenceMismatch(err error) bool {
+	var txErr *TxError
+	return errors.As(err, &txErr) && txErr.IsSequenceMismatch()
+}
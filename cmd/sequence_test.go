@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+)
+
+// testMsg is a message identified by its name only
+type testMsg string
+
+func (m testMsg) Route() string                { return "test" }
+func (m testMsg) Type() string                 { return string(m) }
+func (m testMsg) ValidateBasic() error         { return nil }
+func (m testMsg) GetSignBytes() []byte         { return []byte(m) }
+func (m testMsg) GetSigners() []sdk.AccAddress { return nil }
+
+func testAddress(name string) sdk.AccAddress {
+	return sdk.AccAddress(fmt.Sprintf("%-20s", name)[:20])
+}
+
+// fakeChain checks the sequence of the transactions sent to it like CheckTx does
+type fakeChain struct {
+	mu  sync.Mutex
+	seq uint64
+	// fetches counts the account queries
+	fetches int
+	// sent are the messages and sequence of every transaction sent, joined like "a,b@3"
+	sent []string
+	// reject fails CheckTx of transactions containing these messages
+	reject map[string]bool
+	// unreachable fails every broadcast before reaching the node
+	unreachable bool
+}
+
+func (f *fakeChain) account(addr sdk.AccAddress) (uint64, uint64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.fetches++
+	return 1, f.seq, nil
+}
+
+func (f *fakeChain) send(msgs []sdk.Msg, accNum, seq uint64, scale float64) (sdk.TxResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	for _, msg := range msgs {
+		names = append(names, msg.Type())
+	}
+	f.sent = append(f.sent, strings.Join(names, ",")+"@"+strconv.FormatUint(seq, 10))
+
+	if f.unreachable {
+		return sdk.TxResponse{}, errors.New("connection refused")
+	}
+	if seq != f.seq {
+		return sdk.TxResponse{Codespace: sdkerrors.RootCodespace, Code: sdkerrors.ErrInvalidSequence.ABCICode()}, nil
+	}
+	for _, name := range names {
+		if f.reject[name] {
+			return sdk.TxResponse{Codespace: sdkerrors.RootCodespace, Code: sdkerrors.ErrInvalidRequest.ABCICode(), RawLog: name}, nil
+		}
+	}
+	f.seq++
+	return sdk.TxResponse{TxHash: strings.Join(names, ",")}, nil
+}
+
+func (f *fakeChain) confirm(res sdk.TxResponse, err error, reqs []*txRequest) {
+	for _, req := range reqs {
+		req.done <- txResult{res, err}
+	}
+}
+
+func TestTxQueue(t *testing.T) {
+	tests := []struct {
+		name string
+		// chain is the state of the chain, synced the sequence the queue believes is next, if any
+		chain  *fakeChain
+		synced *uint64
+		// requests are queued before the queue is drained, one request per message
+		requests []string
+		// sent are the transactions the chain saw, results the tx hash of each request or part of its error
+		sent    []string
+		results []string
+		errs    []bool
+		fetches int
+	}{
+		{
+			name:     "single",
+			chain:    &fakeChain{seq: 3},
+			requests: []string{"a"},
+			sent:     []string{"a@3"},
+			results:  []string{"a"},
+			fetches:  1,
+		},
+		{
+			name:     "batched",
+			chain:    &fakeChain{seq: 3},
+			requests: []string{"a", "b", "c"},
+			sent:     []string{"a,b,c@3"},
+			results:  []string{"a,b,c", "a,b,c", "a,b,c"},
+			fetches:  1,
+		},
+		{
+			name:     "failed batch retried individually",
+			chain:    &fakeChain{seq: 3, reject: map[string]bool{"b": true}},
+			requests: []string{"a", "b", "c"},
+			sent:     []string{"a,b,c@3", "a@3", "b@4", "c@4"},
+			results:  []string{"a", "code 18: b", "c"},
+			errs:     []bool{false, true, false},
+			fetches:  3,
+		},
+		{
+			name:     "stale sequence re-synced",
+			chain:    &fakeChain{seq: 5},
+			synced:   uint64Ptr(3),
+			requests: []string{"a"},
+			sent:     []string{"a@3", "a@5"},
+			results:  []string{"a"},
+			fetches:  1,
+		},
+		{
+			name:     "local sequence used while synced",
+			chain:    &fakeChain{seq: 5},
+			synced:   uint64Ptr(5),
+			requests: []string{"a"},
+			sent:     []string{"a@5"},
+			results:  []string{"a"},
+			fetches:  0,
+		},
+		{
+			name:     "broadcast error",
+			chain:    &fakeChain{seq: 3, unreachable: true},
+			requests: []string{"a"},
+			sent:     []string{"a@3"},
+			results:  []string{"connection refused"},
+			errs:     []bool{true},
+			fetches:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &txQueue{addr: testAddress("owner")}
+			if tt.synced != nil {
+				q.accNum, q.seq, q.synced = 1, *tt.synced, true
+			}
+
+			var done []<-chan txResult
+			for i, name := range tt.requests {
+				ch, leader := q.enqueue([]sdk.Msg{testMsg(name)}, 1)
+				if leader != (i == 0) {
+					t.Fatalf("expected only the first request to drain the queue, request %d leader %t", i, leader)
+				}
+				done = append(done, ch)
+			}
+			q.drain(tt.chain)
+
+			for i, ch := range done {
+				out := <-ch
+				switch {
+				case tt.errs != nil && tt.errs[i]:
+					if out.err == nil || !strings.Contains(out.err.Error(), tt.results[i]) {
+						t.Errorf("expected request %s to fail with %q, got %v", tt.requests[i], tt.results[i], out.err)
+					}
+				case out.err != nil:
+					t.Errorf("expected request %s to succeed, got %v", tt.requests[i], out.err)
+				case out.res.TxHash != tt.results[i]:
+					t.Errorf("expected request %s to be sent in tx %q, got %q", tt.requests[i], tt.results[i], out.res.TxHash)
+				}
+			}
+			if strings.Join(tt.chain.sent, " ") != strings.Join(tt.sent, " ") {
+				t.Errorf("expected transactions %v, got %v", tt.sent, tt.chain.sent)
+			}
+			if tt.chain.fetches != tt.fetches {
+				t.Errorf("expected %d account queries, got %d", tt.fetches, tt.chain.fetches)
+			}
+
+			// the queue is released once drained
+			if _, leader := q.enqueue([]sdk.Msg{testMsg("next")}, 1); !leader {
+				t.Error("expected the drained queue to accept a new leader")
+			}
+		})
+	}
+}
+
+func TestTxQueueBatchScale(t *testing.T) {
+	var scales []float64
+	q := &txQueue{addr: testAddress("owner")}
+	q.enqueue([]sdk.Msg{testMsg("a")}, 1)
+	q.enqueue([]sdk.Msg{testMsg("b")}, 1.5)
+	q.enqueue([]sdk.Msg{testMsg("c")}, 1.2)
+	q.drain(&scaleRecorder{fakeChain: &fakeChain{}, scales: &scales})
+	if len(scales) != 1 || scales[0] != 1.5 {
+		t.Errorf("expected one transaction scaled by 1.5, got %v", scales)
+	}
+}
+
+// scaleRecorder records the fee scale of the transactions sent
+type scaleRecorder struct {
+	*fakeChain
+	scales *[]float64
+}
+
+func (s *scaleRecorder) send(msgs []sdk.Msg, accNum, seq uint64, scale float64) (sdk.TxResponse, error) {
+	*s.scales = append(*s.scales, scale)
+	return s.fakeChain.send(msgs, accNum, seq, scale)
+}
+
+func uint64Ptr(n uint64) *uint64 {
+	return &n
+}
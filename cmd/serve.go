@@ -0,0 +1,620 @@
+package cmd
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	"github.com/ovrclk/akash/cmd/common"
+	"github.com/ovrclk/akash/provider/gateway"
+	"github.com/ovrclk/akash/pubsub"
+	dtypes "github.com/ovrclk/akash/x/deployment/types"
+	mtypes "github.com/ovrclk/akash/x/market/types"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/fsnotify.v1"
+)
+
+var (
+	flagToken = "token"
+
+	// envAPIToken is read when --token isn't set, so the token doesn't show in the process list
+	envAPIToken = envPrefix + "_API_TOKEN"
+)
+
+const (
+	// apiMaxSDLSize is the largest sdl accepted in a request body
+	apiMaxSDLSize = 1 << 20
+	// apiKeepAlive is how often an idle event stream is sent a comment
+	apiKeepAlive = 15 * time.Second
+	// apiSubscriberBuffer is how many events a slow event stream can fall behind before they are dropped
+	apiSubscriberBuffer = 64
+)
+
+func init() {
+	rootCmd.AddCommand(serveCmd())
+}
+
+// serveCmd represents the serve command
+func serveCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "serve an http api to manage deployments of the configured key, while listening to the chain like start",
+		Long: `serve an http api to manage deployments of the configured key, while listening to the chain like start
+
+deployments created or updated through the api are followed like with create: the
+manifest is sent when leases are created and the archive is kept up to date.
+active archived deployments are followed again when the server starts. when
+--token or $` + envAPIToken + ` is set, requests need an "Authorization: Bearer <token>" header.
+with --metrics-listen, prometheus metrics are served like with start
+
+  GET    /deployments               archived deployments of the key
+  POST   /deployments[?dseq=N]      create a deployment from the sdl in the body
+  GET    /deployments/{dseq}        archived deployment
+  PUT    /deployments/{dseq}        update a deployment with the sdl in the body
+  DELETE /deployments/{dseq}        close a deployment
+  GET    /deployments/{dseq}/status status of the services, as reported by providers
+  GET    /events                    server-sent events of the chain and config directory`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := config.SetGasOnConfig(); err != nil {
+				return err
+			}
+			if config.GetAccAddress() == nil {
+				return fmt.Errorf("no key loaded, create one with `deploy key-add`")
+			}
+			listen, err := cmd.Flags().GetString(flagListen)
+			if err != nil {
+				return err
+			}
+			token, err := cmd.Flags().GetString(flagToken)
+			if err != nil {
+				return err
+			}
+			if token == "" {
+				token = os.Getenv(envAPIToken)
+			}
+			metricsListen, metricsInterval, err := metricsFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			return common.RunForever(func(ctx context.Context) error {
+				// the event streams end with the context, so the server can shut down
+				group, ctx := errgroup.WithContext(ctx)
+				s := newAPIServer(ctx, token)
+				s.resume()
+
+				srv := &http.Server{Addr: listen, Handler: s}
+				group.Go(func() error {
+					dirs := []string{homePath, path.Join(homePath, "deployments")}
+					return ChainAndFSEmitter(dirs)(ctx, CountEvents, s.handle)
+				})
+				if metricsListen != "" {
+					group.Go(func() error {
+						return serveMetrics(ctx, metricsListen, metricsInterval)
+					})
+				}
+				group.Go(func() error {
+					logger.Info("serving api", "listen", listen, "address", config.GetAccAddress(), "auth", token != "")
+					if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+						return err
+					}
+					return nil
+				})
+				group.Go(func() error {
+					<-ctx.Done()
+					sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					defer cancel()
+					return srv.Shutdown(sctx)
+				})
+				return group.Wait()
+			})
+		},
+	}
+	cmd.Flags().String(flagListen, "127.0.0.1:8080", "address to listen on")
+	cmd.Flags().String(flagToken, "", "bearer token requests have to send, defaults to $"+envAPIToken)
+	addMetricsFlags(cmd)
+	return cmd
+}
+
+// apiServer serves the deployments of the configured key over http and streams the events of the bus
+type apiServer struct {
+	ctx   context.Context
+	token string
+
+	mu      sync.Mutex
+	tracked map[uint64]*trackedDeployment
+
+	subsMu sync.Mutex
+	subs   map[chan apiEvent]struct{}
+}
+
+// trackedDeployment is a deployment the server sends manifests for and archives the events of
+type trackedDeployment struct {
+	dd       *DeploymentData
+	handlers []EventHandler
+}
+
+// apiEvent is an event of the bus sent to the event streams
+type apiEvent struct {
+	Type string
+	Data []byte
+}
+
+// apiError is the body of every error response
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func newAPIServer(ctx context.Context, token string) *apiServer {
+	return &apiServer{
+		ctx:     ctx,
+		token:   token,
+		tracked: make(map[uint64]*trackedDeployment),
+		subs:    make(map[chan apiEvent]struct{}),
+	}
+}
+
+// resume follows the active archived deployments of the key with their latest revision
+func (s *apiServer) resume() {
+	list, err := config.ArchivedDeployments(config.GetAccAddress().String())
+	if err != nil {
+		logger.Error("couldn't read the archive", "err", err)
+		return
+	}
+	for _, a := range list {
+		if a.Closed != nil {
+			continue
+		}
+		log := logger.With("dseq", a.DSeq)
+		_, buf, err := a.Revision(a.Latest().Revision)
+		if err != nil {
+			log.Error("couldn't read archived sdl", "err", err)
+			continue
+		}
+		dd, err := DeploymentDataFromSDL(buf)
+		if err != nil {
+			log.Error("couldn't parse archived sdl", "err", err)
+			continue
+		}
+		dd.DeploymentID = dtypes.DeploymentID{Owner: config.GetAccAddress(), DSeq: a.DSeq}
+		dd.Archive = a
+		if leases, err := config.ActiveLeases(dd.DeploymentID); err == nil {
+			for _, l := range leases {
+				dd.AddLease(l)
+			}
+		}
+		s.track(dd)
+		log.Info("following archived deployment")
+	}
+}
+
+func (s *apiServer) track(dd *DeploymentData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tracked[dd.DeploymentID.DSeq] = &trackedDeployment{
+		dd: dd,
+		handlers: []EventHandler{
+			InstrumentHandler("deployment-data", DeploymentDataUpdateHandler(dd)),
+			InstrumentHandler("send-manifest", SendManifestHander(dd)),
+		},
+	}
+}
+
+func (s *apiServer) untrack(dseq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tracked, dseq)
+}
+
+func (s *apiServer) lookup(dseq uint64) *trackedDeployment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tracked[dseq]
+}
+
+// handle streams the event and passes it to the handlers of its deployment, if it is
+// tracked, or PrintHandler. A deployment whose handlers fail is no longer tracked
+func (s *apiServer) handle(ev pubsub.Event) error {
+	s.broadcast(ev)
+
+	id, ok := eventDeploymentID(ev)
+	if !ok || !id.Owner.Equals(config.GetAccAddress()) {
+		return PrintHandler(ev)
+	}
+	t := s.lookup(id.DSeq)
+	if t == nil {
+		return PrintHandler(ev)
+	}
+	for _, h := range t.handlers {
+		if err := h(ev); err != nil {
+			logger.Error("error handling deployment event, no longer following it", "dseq", id.DSeq, "err", err)
+			s.untrack(id.DSeq)
+			return nil
+		}
+	}
+	if _, closed := ev.(dtypes.EventDeploymentClosed); closed {
+		s.untrack(id.DSeq)
+	}
+	return nil
+}
+
+// eventDeploymentID returns the deployment a chain event is about
+func eventDeploymentID(ev pubsub.Event) (dtypes.DeploymentID, bool) {
+	switch event := ev.(type) {
+	case dtypes.EventDeploymentCreated:
+		return event.ID, true
+	case dtypes.EventDeploymentUpdated:
+		return event.ID, true
+	case dtypes.EventDeploymentClosed:
+		return event.ID, true
+	case dtypes.EventGroupClosed:
+		return event.ID.DeploymentID(), true
+	case mtypes.EventOrderCreated:
+		return event.ID.GroupID().DeploymentID(), true
+	case mtypes.EventOrderClosed:
+		return event.ID.GroupID().DeploymentID(), true
+	case mtypes.EventBidCreated:
+		return event.ID.DeploymentID(), true
+	case mtypes.EventBidClosed:
+		return event.ID.DeploymentID(), true
+	case mtypes.EventLeaseCreated:
+		return event.ID.DeploymentID(), true
+	case mtypes.EventLeaseClosed:
+		return event.ID.DeploymentID(), true
+	}
+	return dtypes.DeploymentID{}, false
+}
+
+// broadcast sends the event to every event stream, dropping it for streams that are behind
+func (s *apiServer) broadcast(ev pubsub.Event) {
+	var (
+		typ              = eventType(ev)
+		data interface{} = ev
+	)
+	switch event := ev.(type) {
+	case fsnotify.Event:
+		data = map[string]string{"name": event.Name, "op": event.Op.String()}
+	case error:
+		data = apiError{Error: event.Error()}
+	}
+	bz, err := json.Marshal(data)
+	if err != nil {
+		logger.Error("couldn't encode event", "type", typ, "err", err)
+		return
+	}
+
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- apiEvent{Type: typ, Data: bz}:
+		default:
+		}
+	}
+}
+
+func (s *apiServer) subscribe() chan apiEvent {
+	ch := make(chan apiEvent, apiSubscriberBuffer)
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	s.subs[ch] = struct{}{}
+	return ch
+}
+
+func (s *apiServer) unsubscribe(ch chan apiEvent) {
+	s.subsMu.Lock()
+	defer s.subsMu.Unlock()
+	delete(s.subs, ch)
+}
+
+func (s *apiServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) != 1 {
+		s.respondError(w, http.StatusUnauthorized, errors.New("missing or invalid bearer token"))
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "events":
+		s.allow(w, r, http.MethodGet, s.events)
+	case len(parts) == 1 && parts[0] == "deployments":
+		switch r.Method {
+		case http.MethodGet:
+			s.listDeployments(w, r)
+		case http.MethodPost:
+			s.createDeployment(w, r)
+		default:
+			s.respondError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s isn't supported", r.Method))
+		}
+	case len(parts) >= 2 && len(parts) <= 3 && parts[0] == "deployments":
+		dseq, err := strconv.ParseUint(parts[1], 10, 64)
+		if err != nil {
+			s.respondError(w, http.StatusBadRequest, fmt.Errorf("invalid dseq %q", parts[1]))
+			return
+		}
+		if len(parts) == 3 {
+			if parts[2] != "status" {
+				s.respondError(w, http.StatusNotFound, errors.New("not found"))
+				return
+			}
+			s.allow(w, r, http.MethodGet, func(w http.ResponseWriter, r *http.Request) { s.deploymentStatus(w, r, dseq) })
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			s.getDeployment(w, r, dseq)
+		case http.MethodPut:
+			s.updateDeployment(w, r, dseq)
+		case http.MethodDelete:
+			s.closeDeployment(w, r, dseq)
+		default:
+			s.respondError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s isn't supported", r.Method))
+		}
+	default:
+		s.respondError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+// allow calls fn if the request has the method
+func (s *apiServer) allow(w http.ResponseWriter, r *http.Request, method string, fn http.HandlerFunc) {
+	if r.Method != method {
+		s.respondError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s isn't supported", r.Method))
+		return
+	}
+	fn(w, r)
+}
+
+func (s *apiServer) listDeployments(w http.ResponseWriter, r *http.Request) {
+	list, err := config.ArchivedDeployments(config.GetAccAddress().String())
+	if err != nil {
+		s.respondError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if list == nil {
+		list = []*ArchivedDeployment{}
+	}
+	s.respond(w, http.StatusOK, list)
+}
+
+func (s *apiServer) getDeployment(w http.ResponseWriter, r *http.Request, dseq uint64) {
+	a, ok := s.archived(w, dseq)
+	if ok {
+		s.respond(w, http.StatusOK, a.Snapshot())
+	}
+}
+
+// archived opens the archive of the deployment, responding with an error if it isn't archived
+func (s *apiServer) archived(w http.ResponseWriter, dseq uint64) (*ArchivedDeployment, bool) {
+	if t := s.lookup(dseq); t != nil && t.dd.Archive != nil {
+		return t.dd.Archive, true
+	}
+	a, err := config.OpenArchivedDeployment(config.GetAccAddress().String(), dseq)
+	if err != nil {
+		s.respondError(w, http.StatusNotFound, err)
+		return nil, false
+	}
+	return a, true
+}
+
+// readSDL reads the sdl in the request body
+func (s *apiServer) readSDL(w http.ResponseWriter, r *http.Request) (*DeploymentData, bool) {
+	buf, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, apiMaxSDLSize))
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, err)
+		return nil, false
+	}
+	dd, err := DeploymentDataFromSDL(buf)
+	if err != nil {
+		s.respondError(w, http.StatusBadRequest, fmt.Errorf("invalid sdl: %w", err))
+		return nil, false
+	}
+	return dd, true
+}
+
+func (s *apiServer) createDeployment(w http.ResponseWriter, r *http.Request) {
+	dd, ok := s.readSDL(w, r)
+	if !ok {
+		return
+	}
+	dd.DeploymentID.Owner = config.GetAccAddress()
+	if v := r.URL.Query().Get("dseq"); v != "" {
+		var err error
+		if dd.DeploymentID.DSeq, err = strconv.ParseUint(v, 10, 64); err != nil {
+			s.respondError(w, http.StatusBadRequest, fmt.Errorf("invalid dseq %q", v))
+			return
+		}
+	} else {
+		var err error
+		if dd.DeploymentID.DSeq, err = config.BlockHeight(); err != nil {
+			s.respondError(w, http.StatusBadGateway, err)
+			return
+		}
+	}
+
+	var err error
+	if dd.Archive, err = config.NewArchivedDeployment(dd); err != nil {
+		s.respondError(w, http.StatusConflict, err)
+		return
+	}
+	// tracked before the tx is sent so no event is missed
+	s.track(dd)
+	if err = config.TxCreateDeployment(dd); err != nil {
+		s.untrack(dd.DeploymentID.DSeq)
+		s.respondError(w, http.StatusBadGateway, err)
+		return
+	}
+	go s.poll(dd)
+	s.respond(w, http.StatusCreated, dd.Archive.Snapshot())
+}
+
+func (s *apiServer) updateDeployment(w http.ResponseWriter, r *http.Request, dseq uint64) {
+	a, ok := s.archived(w, dseq)
+	if !ok {
+		return
+	}
+	// the emitter may be closing the archive, read it under its lock
+	if closed := a.Snapshot().Closed; closed != nil {
+		s.respondError(w, http.StatusConflict, fmt.Errorf("deployment %d was closed on %s", dseq, formatTime(closed)))
+		return
+	}
+	dd, ok := s.readSDL(w, r)
+	if !ok {
+		return
+	}
+	dd.DeploymentID = dtypes.DeploymentID{Owner: config.GetAccAddress(), DSeq: dseq}
+	dd.Archive = a
+
+	leases, err := config.ActiveLeases(dd.DeploymentID)
+	if err != nil {
+		s.respondError(w, http.StatusBadGateway, err)
+		return
+	}
+	for _, l := range leases {
+		dd.AddLease(l)
+	}
+	if err = config.TxUpdateDeployment(dd, "updated through the api"); err != nil {
+		s.respondError(w, http.StatusBadGateway, err)
+		return
+	}
+	// the new manifest is sent to leases created from now on
+	s.track(dd)
+	for _, l := range leases {
+		if err = config.SendManifest(dd, l); err != nil {
+			s.respondError(w, http.StatusBadGateway, fmt.Errorf("sending manifest to %s: %w", l.Provider, err))
+			return
+		}
+	}
+	go s.poll(dd)
+	s.respond(w, http.StatusOK, a.Snapshot())
+}
+
+func (s *apiServer) closeDeployment(w http.ResponseWriter, r *http.Request, dseq uint64) {
+	a, ok := s.archived(w, dseq)
+	if !ok {
+		return
+	}
+	// the emitter may be closing the archive, read it under its lock
+	if closed := a.Snapshot().Closed; closed != nil {
+		s.respondError(w, http.StatusConflict, fmt.Errorf("deployment %d was closed on %s", dseq, formatTime(closed)))
+		return
+	}
+	if err := config.TxCloseDeployment(dtypes.DeploymentID{Owner: config.GetAccAddress(), DSeq: dseq}, a); err != nil {
+		s.respondError(w, http.StatusBadGateway, err)
+		return
+	}
+	s.untrack(dseq)
+	s.respond(w, http.StatusOK, a.Snapshot())
+}
+
+func (s *apiServer) deploymentStatus(w http.ResponseWriter, r *http.Request, dseq uint64) {
+	leases, err := config.ActiveLeases(dtypes.DeploymentID{Owner: config.GetAccAddress(), DSeq: dseq})
+	if err != nil {
+		s.respondError(w, http.StatusBadGateway, err)
+		return
+	}
+	if len(leases) == 0 {
+		s.respondError(w, http.StatusNotFound, fmt.Errorf("deployment %d has no active leases", dseq))
+		return
+	}
+	events, err := deploymentEvents(r.Context(), gateway.NewClient(), leases, 0)
+	if err != nil {
+		s.respondError(w, http.StatusBadGateway, err)
+		return
+	}
+	if events == nil {
+		events = []*serviceEvents{}
+	}
+	s.respond(w, http.StatusOK, events)
+}
+
+// events streams the events of the bus as server-sent events
+func (s *apiServer) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.respondError(w, http.StatusInternalServerError, errors.New("streaming isn't supported"))
+		return
+	}
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(apiKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.ctx.Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case ev := <-ch:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, ev.Data)
+		}
+		flusher.Flush()
+	}
+}
+
+// poll waits for the leases of the deployment and polls its services until they are available
+func (s *apiServer) poll(dd *DeploymentData) {
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	if err := config.WaitForLeasesAndPollService(ctx, dd, cancel); err != nil {
+		logger.Error("error listening for service", "dseq", dd.DeploymentID.DSeq, "err", err)
+	}
+}
+
+func (s *apiServer) respond(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("writing api response", "err", err)
+	}
+}
+
+func (s *apiServer) respondError(w http.ResponseWriter, code int, err error) {
+	s.respond(w, code, apiError{Error: err.Error()})
+}
+
+// TxCloseDeployment closes the deployment and records it as closed in the archive
+func (c *Config) TxCloseDeployment(id dtypes.DeploymentID, a *ArchivedDeployment) error {
+	res, err := c.SendMsgs([]sdk.Msg{dtypes.MsgCloseDeployment{ID: id}})
+	log := logger.With(
+		"hash", res.TxHash,
+		"code", res.Code,
+		"codespace", res.Codespace,
+		"action", "close-deployment",
+		"dseq", id.DSeq,
+	)
+
+	if err != nil {
+		log.Error("tx failed", "log", res.RawLog)
+		return err
+	}
+
+	log.Info("tx sent successfully")
+	if err = a.AddTx("close-deployment", res.TxHash); err != nil {
+		log.Error("error updating archive", "err", err)
+	}
+	if err = a.Close(); err != nil {
+		log.Error("error updating archive", "err", err)
+	}
+	return nil
+}
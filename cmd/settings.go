@@ -0,0 +1,361 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	flagChainID      = "chain-id"
+	flagRPCAddr      = "rpc-addr"
+	flagRPCAddrs     = "rpc-addrs"
+	flagKeyfile      = "keyfile"
+	flagKeypass      = "keypass"
+	flagBech32Prefix = "bech32-prefix"
+	flagGasPrices    = "gas-prices"
+	flagGasAdj       = "gas-adjustment"
+	flagGasAdjMax    = "gas-adjustment-max"
+	flagGas          = "gas"
+	flagFees         = "fees"
+	flagBroadcast    = flags.FlagBroadcastMode
+	flagTxTimeout    = "tx-timeout"
+	flagOutput       = "output"
+	flagProvAllow    = "providers-allow"
+	flagProvDeny     = "providers-deny"
+	flagProvPolicy   = "provider-policy"
+
+	// kinds of settings, they are registered as flags of the matching type
+	kindString   = ""
+	kindFloat    = "float"
+	kindUint     = "uint"
+	kindDuration = "duration"
+
+	// envPrefix is prepended to the upper cased setting key to get its environment variable
+	envPrefix = "DEPLOY"
+)
+
+// setting is a config value that is read from the config file, overridden by
+// a DEPLOY_* environment variable, which is overridden in turn by a flag
+type setting struct {
+	key       string
+	shorthand string
+	def       string
+	usage     string
+	// kind is the type of the flag, a string if empty
+	kind string
+
+	// file is the dot separated path of the setting in a network of the config file, the key if empty
+	file string
+
+	// get returns the value currently on the config, "" if it isn't set
+	get func(*Config) string
+	// set parses the value and sets it on the config
+	set func(*Config, string) error
+}
+
+// filePath returns the keys of the setting in a network of the config file
+func (s setting) filePath() []string {
+	if s.file == "" {
+		return []string{s.key}
+	}
+	return strings.Split(s.file, ".")
+}
+
+// addFlag registers the setting as a flag of its kind
+func (s setting) addFlag(fs *pflag.FlagSet) {
+	switch s.kind {
+	case kindFloat:
+		def, _ := strconv.ParseFloat(s.def, 64)
+		fs.Float64P(s.key, s.shorthand, def, s.usage)
+	case kindUint:
+		def, _ := strconv.ParseUint(s.def, 10, 64)
+		fs.Uint64P(s.key, s.shorthand, def, s.usage)
+	case kindDuration:
+		def, _ := time.ParseDuration(s.def)
+		fs.DurationP(s.key, s.shorthand, def, s.usage)
+	default:
+		fs.StringP(s.key, s.shorthand, s.def, s.usage)
+	}
+}
+
+// flagValue returns the value of the setting's flag, true if it was passed
+func (s setting) flagValue(fs *pflag.FlagSet) (string, bool) {
+	f := fs.Lookup(s.key)
+	if f == nil || !f.Changed {
+		return "", false
+	}
+	return f.Value.String(), true
+}
+
+// settings are all the config values that can be overridden, in the order they are shown
+var settings = []setting{
+	{
+		key:   flagChainID,
+		usage: "chain-id of the network",
+		get:   func(c *Config) string { return c.ChainID },
+		set:   func(c *Config, v string) error { c.ChainID = v; return nil },
+	},
+	{
+		key:   flagRPCAddr,
+		usage: "rpc endpoint of the network",
+		get:   func(c *Config) string { return c.RPCAddr },
+		set:   func(c *Config, v string) error { c.RPCAddr = v; return nil },
+	},
+	{
+		key:   flagRPCAddrs,
+		usage: "comma separated rpc endpoints to fail over to when rpc-addr is unhealthy",
+		get:   func(c *Config) string { return strings.Join(c.RPCAddrs, ",") },
+		set:   func(c *Config, v string) error { c.RPCAddrs = splitList(v); return nil },
+	},
+	{
+		key:   flagKeyfile,
+		def:   "key.priv",
+		usage: "private key file, relative to --home unless absolute",
+		get:   func(c *Config) string { return c.Keyfile },
+		set:   func(c *Config, v string) error { c.Keyfile = v; return nil },
+	},
+	{
+		key:   flagKeypass,
+		def:   defaultPass,
+		usage: "password for the private key file",
+		get:   func(c *Config) string { return c.Keypass },
+		set:   func(c *Config, v string) error { c.Keypass = v; return nil },
+	},
+	{
+		key:   flagBech32Prefix,
+		def:   akashPrefix,
+		usage: "bech32 account address prefix of the network",
+		get:   func(c *Config) string { return c.Bech32Prefix },
+		set:   func(c *Config, v string) error { c.Bech32Prefix = v; return nil },
+	},
+	{
+		key:       flagGasPrices,
+		shorthand: "p",
+		def:       "0.025akash",
+		usage:     "price for gas",
+		get:       func(c *Config) string { return c.GasPrices },
+		set:       func(c *Config, v string) error { c.GasPrices = v; return nil },
+	},
+	{
+		key:       flagGasAdj,
+		shorthand: "a",
+		def:       "1.0",
+		kind:      kindFloat,
+		usage:     "gas adjustment for transactions. out of gas and insufficient fee failures are retried with a higher adjustment",
+		get:       func(c *Config) string { return formatFloat(c.GasAdjustment) },
+		set: func(c *Config, v string) (err error) {
+			c.GasAdjustment, err = strconv.ParseFloat(v, 64)
+			return
+		},
+	},
+	{
+		key:   flagGasAdjMax,
+		def:   "3.0",
+		kind:  kindFloat,
+		usage: "ceiling for the gas adjustment when retrying failed transactions. --gas and --fees are scaled by the same ratio",
+		get:   func(c *Config) string { return formatFloat(c.GasAdjustmentMax) },
+		set: func(c *Config, v string) (err error) {
+			c.GasAdjustmentMax, err = strconv.ParseFloat(v, 64)
+			return
+		},
+	},
+	{
+		key:   flagGas,
+		kind:  kindUint,
+		usage: "fixed gas limit for transactions, unset simulates each transaction to estimate it",
+		get: func(c *Config) string {
+			if c.Gas == 0 {
+				return ""
+			}
+			return strconv.FormatUint(c.Gas, 10)
+		},
+		set: func(c *Config, v string) (err error) {
+			c.Gas, err = strconv.ParseUint(v, 10, 64)
+			return
+		},
+	},
+	{
+		key:   flagFees,
+		usage: "fixed fees to pay for transactions, instead of --gas-prices",
+		get:   func(c *Config) string { return c.Fees },
+		set:   func(c *Config, v string) error { c.Fees = v; return nil },
+	},
+	{
+		key:       flagBroadcast,
+		shorthand: "b",
+		def:       flags.BroadcastSync,
+		usage:     "transaction broadcasting mode (sync|async|block)",
+		get:       func(c *Config) string { return c.BroadcastMode },
+		set: func(c *Config, v string) error {
+			switch v {
+			case flags.BroadcastSync, flags.BroadcastAsync, flags.BroadcastBlock:
+				c.BroadcastMode = v
+				return nil
+			}
+			return fmt.Errorf("expected one of sync, async or block, got %q", v)
+		},
+	},
+	{
+		key:   flagTxTimeout,
+		def:   "30s",
+		kind:  kindDuration,
+		usage: "how long to wait for a broadcast transaction to be included in a block",
+		get: func(c *Config) string {
+			if c.TxTimeout == 0 {
+				return ""
+			}
+			return c.TxTimeout.String()
+		},
+		set: func(c *Config, v string) (err error) {
+			c.TxTimeout, err = time.ParseDuration(v)
+			return
+		},
+	},
+	{
+		key:       flagOutput,
+		shorthand: "o",
+		def:       "text",
+		usage:     "output format (text|json)",
+		get:       func(c *Config) string { return c.Output },
+		set: func(c *Config, v string) error {
+			switch v {
+			case "text", "json":
+				c.Output = v
+				return nil
+			}
+			return fmt.Errorf("expected one of text or json, got %q", v)
+		},
+	},
+	{
+		key:   flagProvAllow,
+		file:  "providers.allow",
+		usage: "comma separated provider addresses or key=value attributes leases are only allowed with, unset allows any provider",
+		get:   func(c *Config) string { return strings.Join(c.Providers.Allow, ",") },
+		set: func(c *Config, v string) (err error) {
+			c.Providers.Allow, err = splitProviderRules(v)
+			return
+		},
+	},
+	{
+		key:   flagProvDeny,
+		file:  "providers.deny",
+		usage: "comma separated provider addresses or key=value attributes leases aren't allowed with",
+		get:   func(c *Config) string { return strings.Join(c.Providers.Deny, ",") },
+		set: func(c *Config, v string) (err error) {
+			c.Providers.Deny, err = splitProviderRules(v)
+			return
+		},
+	},
+	{
+		key:   flagProvPolicy,
+		def:   providerPolicyClose,
+		usage: "when a lease is created with a provider that isn't allowed, close it so the order is re-opened, or fail (close|fail)",
+		get:   func(c *Config) string { return c.ProviderPolicy },
+		set: func(c *Config, v string) error {
+			switch v {
+			case providerPolicyClose, providerPolicyFail:
+				c.ProviderPolicy = v
+				return nil
+			}
+			return fmt.Errorf("expected one of close or fail, got %q", v)
+		},
+	},
+}
+
+func init() {
+	for _, s := range settings {
+		s.addFlag(rootCmd.PersistentFlags())
+	}
+}
+
+// envName returns the environment variable for a setting key, e.g. DEPLOY_RPC_ADDR for rpc-addr
+func envName(key string) string {
+	return envPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// lookupSetting returns the setting for the key
+func lookupSetting(key string) (setting, error) {
+	for _, s := range settings {
+		if s.key == key {
+			return s, nil
+		}
+	}
+	return setting{}, fmt.Errorf("unknown setting %q", key)
+}
+
+// resolveSettings overrides the values read from the config file with the
+// environment and then the flags, falling back to the flag defaults. Where
+// each value came from is recorded for `config show --resolved`
+func (c *Config) resolveSettings(cmd *cobra.Command) error {
+	c.sources = make(map[string]string)
+	for _, s := range settings {
+		var val, src string
+		env, inEnv := os.LookupEnv(envName(s.key))
+		flag, inFlags := s.flagValue(cmd.PersistentFlags())
+		switch {
+		case inFlags:
+			val, src = flag, "flag --"+s.key
+		case inEnv:
+			val, src = env, "env "+envName(s.key)
+		case s.get(c) != "":
+			c.sources[s.key] = "file"
+			continue
+		case s.def != "":
+			val, src = s.def, "default"
+		default:
+			continue
+		}
+		if err := s.set(c, val); err != nil {
+			return fmt.Errorf("invalid %s from %s: %w", s.key, src, err)
+		}
+		c.sources[s.key] = src
+	}
+	return nil
+}
+
+// Source returns where the resolved value of a setting came from
+func (c *Config) Source(key string) string {
+	if src, ok := c.sources[key]; ok {
+		return src
+	}
+	return "unset"
+}
+
+// sourceRank orders the sources of settings by precedence, higher wins
+func sourceRank(src string) int {
+	switch {
+	case strings.HasPrefix(src, "flag"):
+		return 3
+	case strings.HasPrefix(src, "env"):
+		return 2
+	case src == "file":
+		return 1
+	case src == "default":
+		return 0
+	}
+	return -1
+}
+
+func formatFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
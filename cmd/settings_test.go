@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func TestResolveSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file Network
+		// check returns the resolved value of the setting key
+		check   func(c *Config) interface{}
+		key     string
+		want    interface{}
+		wantSrc string
+		err     bool
+	}{
+		{
+			name:    "float flag",
+			args:    []string{"--gas-adjustment", "1.5"},
+			file:    Network{GasAdjustment: 1.2},
+			check:   func(c *Config) interface{} { return c.GasAdjustment },
+			key:     flagGasAdj,
+			want:    1.5,
+			wantSrc: "flag --gas-adjustment",
+		},
+		{
+			name:    "env over file",
+			env:     map[string]string{"DEPLOY_GAS_ADJUSTMENT": "1.3"},
+			file:    Network{GasAdjustment: 1.2},
+			check:   func(c *Config) interface{} { return c.GasAdjustment },
+			key:     flagGasAdj,
+			want:    1.3,
+			wantSrc: "env DEPLOY_GAS_ADJUSTMENT",
+		},
+		{
+			name:    "file over default",
+			file:    Network{GasAdjustment: 1.2},
+			check:   func(c *Config) interface{} { return c.GasAdjustment },
+			key:     flagGasAdj,
+			want:    1.2,
+			wantSrc: "file",
+		},
+		{
+			name:    "uint flag",
+			args:    []string{"--gas", "200000"},
+			check:   func(c *Config) interface{} { return c.Gas },
+			key:     flagGas,
+			want:    uint64(200000),
+			wantSrc: "flag --gas",
+		},
+		{
+			name:    "duration default",
+			check:   func(c *Config) interface{} { return c.TxTimeout },
+			key:     flagTxTimeout,
+			want:    30 * time.Second,
+			wantSrc: "default",
+		},
+		{
+			name:    "duration flag",
+			args:    []string{"--tx-timeout", "1m"},
+			check:   func(c *Config) interface{} { return c.TxTimeout },
+			key:     flagTxTimeout,
+			want:    time.Minute,
+			wantSrc: "flag --tx-timeout",
+		},
+		{
+			name: "invalid flag",
+			args: []string{"--gas", "lots"},
+			err:  true,
+		},
+		{
+			name: "invalid env",
+			env:  map[string]string{"DEPLOY_GAS": "lots"},
+			err:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			cmd := &cobra.Command{}
+			for _, s := range settings {
+				s.addFlag(cmd.PersistentFlags())
+			}
+			err := cmd.PersistentFlags().Parse(tt.args)
+			c := &Config{Network: tt.file}
+			if err == nil {
+				err = c.resolveSettings(cmd)
+			}
+			if tt.err {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.check(c); got != tt.want {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+			if src := c.Source(tt.key); src != tt.wantSrc {
+				t.Errorf("expected source %q, got %q", tt.wantSrc, src)
+			}
+		})
+	}
+}
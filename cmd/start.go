@@ -20,9 +20,11 @@ import (
 
 	"github.com/ovrclk/akash/cmd/common"
 	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
 )
 
 func init() {
+	addMetricsFlags(startCmd)
 	rootCmd.AddCommand(startCmd)
 }
 
@@ -30,10 +32,30 @@ func init() {
 var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Listen to the chain and configuration directory and print those events",
+	Long: `Listen to the chain and configuration directory and print those events
+
+with --metrics-listen, prometheus metrics are served on /metrics: events by type,
+handler errors and latencies, transaction broadcasts by result code, gas used, rpc
+reconnects, and, polled every --metrics-interval for the configured key, its balance,
+active deployments and leases, and the available and total replicas of each service
+as reported by the providers`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		metricsListen, metricsInterval, err := metricsFlags(cmd)
+		if err != nil {
+			return err
+		}
 		return common.RunForever(func(ctx context.Context) error {
-			dirs := []string{homePath, path.Join(homePath, "deployments")}
-			return ChainAndFSEmitter(dirs)(ctx, PrintHandler)
+			group, ctx := errgroup.WithContext(ctx)
+			group.Go(func() error {
+				dirs := []string{homePath, path.Join(homePath, "deployments")}
+				return ChainAndFSEmitter(dirs)(ctx, CountEvents, InstrumentHandler("print", PrintHandler))
+			})
+			if metricsListen != "" {
+				group.Go(func() error {
+					return serveMetrics(ctx, metricsListen, metricsInterval)
+				})
+			}
+			return group.Wait()
 		})
 	},
 }
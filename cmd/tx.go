@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cosmos/cosmos-sdk/client/flags"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+	sdkerrors "github.com/cosmos/cosmos-sdk/types/errors"
+	"github.com/cosmos/cosmos-sdk/x/auth"
+	authclient "github.com/cosmos/cosmos-sdk/x/auth/client/utils"
+)
+
+var (
+	// gasAdjStep is the factor the gas adjustment is raised by between retries
+	gasAdjStep = 1.5
+)
+
+// TxError is returned when a transaction is rejected by CheckTx or fails in DeliverTx
+type TxError struct {
+	TxHash    string
+	Code      uint32
+	Codespace string
+	RawLog    string
+}
+
+// Error implements the error interface
+func (e *TxError) Error() string {
+	return fmt.Sprintf("tx %s failed: codespace %q, code %d: %s", e.TxHash, e.Codespace, e.Code, e.RawLog)
+}
+
+// NewTxError returns a *TxError if the response carries a non-zero ABCI code and nil otherwise
+func NewTxError(res sdk.TxResponse) error {
+	if res.Code == 0 {
+		return nil
+	}
+	return &TxError{
+		TxHash:    res.TxHash,
+		Code:      res.Code,
+		Codespace: res.Codespace,
+		RawLog:    res.RawLog,
+	}
+}
+
+// IsOutOfGas returns true if the transaction ran out of gas
+func (e *TxError) IsOutOfGas() bool {
+	return e.Codespace == sdkerrors.RootCodespace && e.Code == sdkerrors.ErrOutOfGas.ABCICode()
+}
+
+// IsInsufficientFee returns true if the transaction was rejected for paying too little in fees
+func (e *TxError) IsInsufficientFee() bool {
+	return e.Codespace == sdkerrors.RootCodespace && e.Code == sdkerrors.ErrInsufficientFee.ABCICode()
+}
+
+// SendMsgs sends given sdk messages and waits for the transaction to be included in a block.
+// Concurrent calls are serialized through the account's txQueue and may be batched
+// into a single transaction. Out of gas and insufficient fee failures are retried
+// with an escalating gas adjustment up to --gas-adjustment-max. A transaction that
+// fails CheckTx or DeliverTx is returned as a *TxError
+func (c *Config) SendMsgs(datagrams []sdk.Msg) (res sdk.TxResponse, err error) {
+	// validate basic all the msgs
+	for _, msg := range datagrams {
+		if err := msg.ValidateBasic(); err != nil {
+			return res, err
+		}
+	}
+
+	log := logger.With("action", "send-tx")
+	scale, maxScale := 1.0, c.maxFeeScale()
+	for attempt := 1; ; attempt++ {
+		q := c.txQueue(c.GetAccAddress())
+		done, leader := q.enqueue(datagrams, scale)
+		if leader {
+			go q.drain(c)
+		}
+		out := <-done
+		if res, err = out.res, out.err; !isFeeError(err) || scale >= maxScale {
+			return res, err
+		}
+
+		if scale *= gasAdjStep; scale > maxScale {
+			scale = maxScale
+		}
+		log.Info("retrying tx with higher gas adjustment", "attempt", attempt+1, "gas-adjustment", c.GasAdjustment*scale, "err", err)
+	}
+}
+
+// maxFeeScale returns how far the gas and fees of a transaction may be scaled up when retrying
+func (c *Config) maxFeeScale() float64 {
+	if c.GasAdjustment <= 0 || c.GasAdjustmentMax <= c.GasAdjustment {
+		return 1
+	}
+	return c.GasAdjustmentMax / c.GasAdjustment
+}
+
+// confirmTx waits for a transaction that passed CheckTx to be included in a block
+func (c *Config) confirmTx(res sdk.TxResponse) (sdk.TxResponse, error) {
+	// block mode already waited for the DeliverTx result
+	if c.BroadcastMode == flags.BroadcastBlock {
+		return res, nil
+	}
+
+	res, err := c.WaitForTx(res.TxHash)
+	if err != nil {
+		return res, err
+	}
+	return res, NewTxError(res)
+}
+
+// BuildAndSignTx takes messages and builds, signs and marshals a sdk.Tx to prepare it for broadcast.
+// scale multiplies the gas adjustment, or the fixed gas and fees, when retrying a transaction
+func (c *Config) BuildAndSignTx(msgs []sdk.Msg, accNum, seq uint64, scale float64) ([]byte, error) {
+	var err error
+	ctx := c.CLICtx(c.NewTMClient())
+
+	// Scale the fixed fees along with the gas
+	fees := sdk.NewCoins()
+	for _, fee := range c.fees {
+		amt := fee.Amount.ToDec().Mul(sdk.MustNewDecFromStr(fmt.Sprintf("%f", scale)))
+		fees = fees.Add(sdk.NewCoin(fee.Denom, amt.Ceil().RoundInt()))
+	}
+
+	// Create the transaction builder with some sane defaults
+	// TODO: add some debug output?
+	txBldr := auth.NewTxBuilder(
+		auth.DefaultTxEncoder(c.Amino),
+		accNum,
+		seq,
+		uint64(float64(c.Gas)*scale),
+		c.GasAdjustment*scale,
+		c.Gas == 0,
+		c.ChainID,
+		"",
+		fees,
+		c.gasPrices,
+	).WithKeybase(c.keybase)
+
+	// Estimate the gas unless a fixed limit was given
+	if txBldr.SimulateAndExecute() {
+		if txBldr, err = authclient.EnrichWithGas(txBldr, ctx, msgs); err != nil {
+			return nil, err
+		}
+	}
+
+	// Return nil or the signature error
+	return txBldr.BuildAndSign(defaultKey, c.Keypass, msgs)
+}
+
+// BroadcastTx takes the marshaled transaction bytes and broadcasts them using the configured broadcast mode
+func (c *Config) BroadcastTx(txBytes []byte) (sdk.TxResponse, error) {
+	return c.CLICtx(c.NewTMClient()).BroadcastTx(txBytes)
+}
+
+// WaitForTx polls the node for the transaction with the given hash until it
+// has been included in a block or the configured timeout expires
+func (c *Config) WaitForTx(hash string) (sdk.TxResponse, error) {
+	log := logger.With("hash", hash, "action", "confirm-tx")
+	ctx := c.CLICtx(c.NewTMClient())
+	timeout := time.After(c.TxTimeout)
+	tick := time.NewTicker(500 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case <-timeout:
+			return sdk.TxResponse{TxHash: hash}, fmt.Errorf("timed out (%s) waiting for tx %s to be included in a block", c.TxTimeout, hash)
+		case <-tick.C:
+			res, err := authclient.QueryTx(ctx, hash)
+			if err != nil {
+				// the node returns an error until the tx is indexed
+				continue
+			}
+			log.Debug("tx included in block", "height", res.Height)
+			return res, nil
+		}
+	}
+}
+
+func isFeeError(err error) bool {
+	var txErr *TxError
+	return errors.As(err, &txErr) && (txErr.IsOutOfGas() || txErr.IsInsufficientFee())
+}
@@ -7,6 +7,8 @@ AKASH_DATA="$(pwd)/data"
 CLIENT_DATA="$AKASH_DATA/client"
 KEYPASS="12345678"
 KEYFILE="key.priv"
+FAUCET_KEYFILE="faucet.priv"
+FAUCET_ADDR="http://localhost:8081"
 CONFIG="$DEPLOY_DATA/config.yaml"
 
 
@@ -26,11 +28,25 @@ echo "Removing old ~/.akash-deploy and reconfiguring..."
 rm -rf $DEPLOY_DATA &> /dev/null
 mkdir -p $DEPLOY_DATA &> /dev/null
 
-# Export the deployment private key
-printf "$KEYPASS\n$KEYPASS\n" | akashctl --home $CLIENT_DATA keys export main 2> $DEPLOY_DATA/$KEYFILE
+# Export the funded genesis key for the faucet
+printf "$KEYPASS\n$KEYPASS\n" | akashctl --home $CLIENT_DATA keys export main 2> $DEPLOY_DATA/$FAUCET_KEYFILE
 
 # Create the configuration file
-echo "chain-id: $CHAIN_ID" > $CONFIG
-echo "rpc-addr: $RPC_ADDR" >> $CONFIG
-echo "keyfile: $KEYFILE" >> $CONFIG
-echo "keypass: $KEYPASS" >> $CONFIG
+echo "default-network: local" > $CONFIG
+echo "networks:" >> $CONFIG
+echo "  local:" >> $CONFIG
+echo "    chain-id: $CHAIN_ID" >> $CONFIG
+echo "    rpc-addr: $RPC_ADDR" >> $CONFIG
+echo "    keyfile: $KEYFILE" >> $CONFIG
+echo "    keypass: $KEYPASS" >> $CONFIG
+
+# Create a fresh deployer key
+deploy key-add
+
+# Start the local faucet with the genesis key and fund the deployer key from it
+echo "Running faucet on $FAUCET_ADDR..."
+pkill -f "deploy faucet serve" &> /dev/null
+deploy faucet serve --keyfile $FAUCET_KEYFILE > $AKASH_DATA/faucet.log 2>&1 &
+sleep 2
+deploy faucet request $FAUCET_ADDR
+deploy balance --watch